@@ -0,0 +1,342 @@
+// Package account exports a user's personal data and carries out account
+// deletion: a request starts a grace period, after which the purge loop
+// anonymises the account and retires its dependent rows.
+package account
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/mailer"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/photo"
+	"github.com/langbridge/backend/session"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+var ErrNoPendingDeletion = errors.New("no pending account deletion")
+
+// Export is everything we hold about one user.
+type Export struct {
+	ExportTime    time.Time               `json:"export_time"`
+	User          model.UserInfo          `json:"user"`
+	Profile       *model.UserProfile      `json:"profile"`
+	Members       []model.UserMember      `json:"members"`
+	Courses       []model.UserCourse      `json:"courses"`
+	Bookings      []model.CourseBookTrans `json:"bookings"`
+	LessonRecords []model.CourseLogRecord `json:"lesson_records"`
+	Identities    []model.UserIdentity    `json:"identities"`
+	Wallets       []model.UserWallet      `json:"wallets"`
+
+	MemberLogins      []model.UserInfo         `json:"member_logins"` // members' own logins
+	GuardianConsents  []model.GuardianConsent  `json:"guardian_consents"`
+	AuditEvents       []model.AuditEvent       `json:"audit_events"` // on the account or by it
+	NotifyPreferences []model.NotifyPreference `json:"notify_preferences"`
+	NotifySettings    []model.NotifySetting    `json:"notify_settings"`
+	Notifications     []model.Notification     `json:"notifications"`
+	PlacementSessions []model.PlacementSession `json:"placement_sessions"`
+	LearnerLevels     []model.LearnerLevel     `json:"learner_levels"`
+	LearningGoals     []model.LearningGoal     `json:"learning_goals"`
+}
+
+func Collect(userID uint64) (*Export, error) {
+	db := system.GetDb()
+	e := &Export{ExportTime: time.Now()}
+	if err := db.Model(&model.UserInfo{}).Where("id = ?", userID).First(&e.User).Error; err != nil {
+		return nil, err
+	}
+
+	var profile model.UserProfile
+	err := db.Model(&model.UserProfile{}).Where("user_id = ?", userID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if profile.ID > 0 {
+		e.Profile = &profile
+	}
+
+	queries := []struct {
+		model any
+		dest  any
+	}{
+		{&model.UserMember{}, &e.Members},
+		{&model.UserCourse{}, &e.Courses},
+		{&model.CourseBookTrans{}, &e.Bookings},
+		{&model.UserIdentity{}, &e.Identities},
+		{&model.UserWallet{}, &e.Wallets},
+		{&model.NotifyPreference{}, &e.NotifyPreferences},
+		{&model.NotifySetting{}, &e.NotifySettings},
+		{&model.Notification{}, &e.Notifications},
+		{&model.PlacementSession{}, &e.PlacementSessions},
+		{&model.LearnerLevel{}, &e.LearnerLevels},
+		{&model.LearningGoal{}, &e.LearningGoals},
+	}
+	for _, q := range queries {
+		if err := db.Model(q.model).Where("user_id = ?", userID).Order("id asc").Find(q.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	err = db.Model(&model.GuardianConsent{}).Where("guardian_id = ?", userID).Order("id asc").Find(&e.GuardianConsents).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&model.AuditEvent{}).Where("user_id = ? or actor_id = ?", userID, userID).Order("id asc").Find(&e.AuditEvents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var loginIDs []uint64
+	for _, m := range e.Members {
+		if m.LoginUserID > 0 {
+			loginIDs = append(loginIDs, m.LoginUserID)
+		}
+	}
+	if len(loginIDs) > 0 {
+		if err := db.Model(&model.UserInfo{}).Where("id in ?", loginIDs).Order("id asc").Find(&e.MemberLogins).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if len(e.Bookings) > 0 {
+		bookIDs := make([]uint64, len(e.Bookings))
+		for i, b := range e.Bookings {
+			bookIDs[i] = b.ID
+		}
+		err := db.Model(&model.CourseLogRecord{}).Where("book_id in ?", bookIDs).Order("id asc").Find(&e.LessonRecords).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// WriteZip writes the export as a ZIP archive with one JSON file per section.
+func (e *Export) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"user.json", e.User},
+		{"profile.json", e.Profile},
+		{"members.json", e.Members},
+		{"courses.json", e.Courses},
+		{"bookings.json", e.Bookings},
+		{"lesson_records.json", e.LessonRecords},
+		{"identities.json", e.Identities},
+		{"wallets.json", e.Wallets},
+		{"member_logins.json", e.MemberLogins},
+		{"guardian_consents.json", e.GuardianConsents},
+		{"audit_events.json", e.AuditEvents},
+		{"notify_preferences.json", e.NotifyPreferences},
+		{"notify_settings.json", e.NotifySettings},
+		{"notifications.json", e.Notifications},
+		{"placement_sessions.json", e.PlacementSessions},
+		{"learner_levels.json", e.LearnerLevels},
+		{"learning_goals.json", e.LearningGoals},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: e.ExportTime})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func gracePeriod() time.Duration {
+	days := config.GetConfig().Auth.DeletionGraceDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RequestDeletion schedules the user's account for purging. Asking again
+// while a request is pending returns that request unchanged.
+func RequestDeletion(userInfo model.UserInfo) (*model.AccountDeletion, error) {
+	if pending, err := PendingDeletion(userInfo.ID); err == nil {
+		return pending, nil
+	} else if !errors.Is(err, ErrNoPendingDeletion) {
+		return nil, err
+	}
+
+	now := time.Now()
+	deletion := &model.AccountDeletion{
+		UserID:      userInfo.ID,
+		Status:      model.DELETION_PENDING,
+		RequestTime: now,
+		PurgeAfter:  now.Add(gracePeriod()),
+		UpdateTime:  now,
+	}
+	if err := system.GetDb().Create(deletion).Error; err != nil {
+		return nil, err
+	}
+
+	err := mailer.Send(mailer.Message{
+		To:      userInfo.Email,
+		Subject: "Your LangBridge account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to delete your LangBridge account. Your personal data will be erased on %s.\n\nIf you did not ask for this, sign in and cancel the deletion from your profile before then.\n",
+			userInfo.Name, deletion.PurgeAfter.Format("2006-01-02")),
+	})
+	if err != nil {
+		log.Error("[Account] send deletion notice error: ", userInfo.ID, err)
+	}
+	return deletion, nil
+}
+
+func PendingDeletion(userID uint64) (*model.AccountDeletion, error) {
+	var deletion model.AccountDeletion
+	err := system.GetDb().Model(&model.AccountDeletion{}).
+		Where("user_id = ? and status = ?", userID, model.DELETION_PENDING).
+		First(&deletion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoPendingDeletion
+		}
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+func CancelDeletion(userID uint64) error {
+	result := system.GetDb().Model(&model.AccountDeletion{}).
+		Where("user_id = ? and status = ?", userID, model.DELETION_PENDING).
+		Updates(map[string]interface{}{"status": model.DELETION_CANCELLED, "update_time": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoPendingDeletion
+	}
+	return nil
+}
+
+// Purge anonymises the account and soft-deletes what hangs off it. Bookings
+// and lesson records are kept for teacher accounting; once the user row is
+// anonymised they no longer identify anyone. Members' own logins go with
+// the guardian's account.
+func Purge(userID uint64) error {
+	now := time.Now()
+	var memberLogins []uint64
+	system.GetDb().Model(&model.UserMember{}).Where("user_id = ? and login_user_id > ?", userID, 0).
+		Pluck("login_user_id", &memberLogins)
+	var userNo, avatar string
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).Select("user_no").Scan(&userNo)
+	system.GetDb().Model(&model.UserProfile{}).Where("user_id = ?", userID).Select("avatar").Scan(&avatar)
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		steps := []*gorm.DB{
+			tx.Model(&model.UserInfo{}).Where("id = ?", userID).Updates(map[string]interface{}{
+				"email":       fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+				"name":        "Deleted user",
+				"login_id":    "",
+				"password":    "",
+				"status":      model.USER_STATUS_DELETED,
+				"update_time": now,
+			}),
+			tx.Model(&model.UserProfile{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+				"nick_name":       "",
+				"avatar":          "",
+				"contact_phone":   "",
+				"native_language": "",
+				"update_time":     now,
+			}),
+			tx.Model(&model.UserMember{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+				"name":        "",
+				"email":       "",
+				"rel_desc":    "",
+				"birthday":    "",
+				"personality": "",
+				"character":   "",
+				"flag":        -1,
+				"update_time": now,
+			}),
+			tx.Model(&model.UserCourse{}).Where("user_id = ?", userID).Update("flag", -1),
+			tx.Model(&model.UserRole{}).Where("user_id = ?", userID).Update("flag", -1),
+			tx.Model(&model.UserPermission{}).Where("user_id = ?", userID).Update("flag", -1),
+			tx.Model(&model.UserWallet{}).Where("user_id = ?", userID).Update("user_id", 0),
+			tx.Where("user_id = ?", userID).Delete(&model.UserIdentity{}),
+			tx.Where("user_id = ?", userID).Delete(&model.UserTwoFactor{}),
+			tx.Where("user_id = ?", userID).Delete(&model.NotifyPreference{}),
+			tx.Where("user_id = ?", userID).Delete(&model.NotifySetting{}),
+			tx.Where("user_id = ?", userID).Delete(&model.Notification{}),
+			tx.Where("user_id = ?", userID).Delete(&model.PlacementSession{}),
+			tx.Where("user_id = ?", userID).Delete(&model.LearnerLevel{}),
+			tx.Model(&model.LearningGoal{}).Where("user_id = ?", userID).Update("flag", -1),
+			tx.Where("guardian_id = ?", userID).Delete(&model.GuardianConsent{}),
+			// the events stay for the audit trail, without the personal data
+			// their diffs and addresses carry
+			tx.Model(&model.AuditEvent{}).Where("user_id = ? or actor_id = ?", userID, userID).
+				Updates(map[string]interface{}{"diff": "{}", "ip": ""}),
+			tx.Model(&model.AccountDeletion{}).Where("user_id = ? and status = ?", userID, model.DELETION_PENDING).
+				Updates(map[string]interface{}{"status": model.DELETION_DONE, "update_time": now}),
+		}
+		for _, step := range steps {
+			if step.Error != nil {
+				return step.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := session.RevokeAll(userID); err != nil {
+		log.Error("[Account] revoke sessions after purge error: ", userID, err)
+	}
+	photo.Discard(context.Background(), photo.AvatarDir(userNo), avatar)
+	for _, loginID := range memberLogins {
+		if err := Purge(loginID); err != nil {
+			log.Error("[Account] purge member login error: ", loginID, err)
+		}
+	}
+	return nil
+}
+
+const purgeInterval = time.Hour
+
+// StartPurgeLoop purges accounts whose grace period is over, once an hour.
+// A Redis lock keeps several instances from doing the same round.
+func StartPurgeLoop() {
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			purgeDue()
+		}
+	}()
+}
+
+func purgeDue() {
+	if first, err := system.Throttle("lb:account:purge", purgeInterval-time.Minute); err != nil || !first {
+		return
+	}
+	var due []model.AccountDeletion
+	err := system.GetDb().Model(&model.AccountDeletion{}).
+		Where("status = ? and purge_after <= ?", model.DELETION_PENDING, time.Now()).
+		Find(&due).Error
+	if err != nil {
+		log.Error("[Account] query due deletions error: ", err)
+		return
+	}
+	for _, d := range due {
+		if err := Purge(d.UserID); err != nil {
+			log.Error("[Account] purge error: ", d.UserID, err)
+			continue
+		}
+		log.Info("[Account] purged user ", d.UserID)
+	}
+}
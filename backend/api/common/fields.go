@@ -0,0 +1,50 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/imaging"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/photo"
+	"github.com/langbridge/backend/validation"
+)
+
+// InvalidFields turns res into a bad-params error listing every field, and
+// returns false if there were any.
+func InvalidFields(res *Response, errs validation.Errors) bool {
+	if len(errs) == 0 {
+		return true
+	}
+	res.Code = codes.CODE_ERR_BAD_PARAMS
+	res.Msg = errs.Error()
+	res.Data = map[string]any{"errors": errs}
+	return false
+}
+
+// UploadFailed fills res for an error returned by photo.Upload.
+func UploadFailed(res *Response, err error) {
+	switch {
+	case errors.Is(err, photo.ErrStorage):
+		log.Error("[Photo] storage error: ", err)
+		res.Code = codes.CODE_ERR_CONFIG
+		res.Msg = "file storage unavailable"
+	case errors.Is(err, photo.ErrTooLarge):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = fmt.Sprintf("file too large, the limit is %d KB", photo.MaxUploadSize()>>10)
+	case errors.Is(err, photo.ErrNoFile):
+		res.Code = codes.CODE_ERR_PARA_EMPTY
+		res.Msg = "please choose a file"
+	case errors.Is(err, imaging.ErrUnsupported):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "please upload a JPEG, PNG or GIF image"
+	case errors.Is(err, imaging.ErrDimensions):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = fmt.Sprintf("image too large, at most %d pixels wide and high and %d megapixels", imaging.MAX_DIMENSION, imaging.MAX_PIXELS/1_000_000)
+	default:
+		log.Error("[Photo] save error: ", err)
+		res.Code = codes.CODE_ERR_REMOTE
+		res.Msg = "save file failed"
+	}
+}
@@ -8,8 +8,15 @@ import (
 	"time"
 )
 
+// TOKEN_DURATION is the lifetime of session-less tokens issued before refresh
+// tokens existed.
 const TOKEN_DURATION = 10 * 24 * time.Hour
 
+const (
+	ACCESS_TOKEN_DURATION  = 15 * time.Minute
+	REFRESH_TOKEN_DURATION = 30 * 24 * time.Hour
+)
+
 type HeaderParam struct {
 	AppId     string
 	AuthToken string
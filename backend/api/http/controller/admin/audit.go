@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+)
+
+// AuditList pages through the audit log, newest first. ?user_id= narrows it
+// to events by or about one user, ?action= to one action, and ?from= / ?to=
+// (unix seconds) to a time range.
+func AuditList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	q := audit.Query{Action: c.Query("action")}
+	q.PageNo, _ = strconv.ParseInt(c.Query("pn"), 10, 64)
+	q.PageSize, _ = strconv.ParseInt(c.Query("ps"), 10, 64)
+	if q.PageNo <= 0 {
+		q.PageNo = 1
+	}
+	if q.PageSize <= 0 || q.PageSize > 100 {
+		q.PageSize = 20
+	}
+
+	var err error
+	if v := c.Query("user_id"); len(v) > 0 {
+		if q.UserID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid user_id"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		v := c.Query(p.name)
+		if len(v) == 0 {
+			continue
+		}
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid " + p.name
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		*p.dest = time.Unix(ts, 0)
+	}
+
+	list, total, err := audit.Find(q)
+	if err != nil {
+		log.Error("list audit events error: ", err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load audit events failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"list":        list,
+		"pn":          q.PageNo,
+		"ps":          q.PageSize,
+		"total":       total,
+		"total_pages": (total + q.PageSize - 1) / q.PageSize,
+	}
+	c.JSON(http.StatusOK, res)
+}
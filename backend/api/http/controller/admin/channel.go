@@ -0,0 +1,276 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_KEY_OVERLAP = 24 * time.Hour
+	MAX_KEY_OVERLAP     = 30 * 24 * time.Hour
+)
+
+type ChannelCreateRequest struct {
+	AppID     string `json:"app_id" binding:"required,min=2,max=64"`
+	Chan      string `json:"chan" binding:"required,oneof=http ws"`
+	SigMethod string `json:"sig_method" binding:"required,oneof=SHA256 HMAC-SHA256 ED25519"`
+	PublicKey string `json:"public_key"` // base64, ED25519 only
+}
+
+type ChannelRotateRequest struct {
+	ID        uint64 `json:"id" binding:"required"`
+	PublicKey string `json:"public_key"` // the new key, ED25519 only
+	Overlap   int64  `json:"overlap"`    // seconds the old key keeps working, default one day
+}
+
+type ChannelDisableRequest struct {
+	ID uint64 `json:"id" binding:"required"`
+}
+
+// ChannelCredentials is returned on create and rotate. AppKey is only ever
+// shown here; it is not readable afterwards.
+type ChannelCredentials struct {
+	Channel model.SysChannel `json:"channel"`
+	AppKey  string           `json:"app_key,omitempty"`
+}
+
+// channelChanged tells every instance, this one included, to reload channels.
+func channelChanged(appID string) {
+	system.PublishToChan(codes.TOPIC_CHANNEL_CHANGED, []byte(appID))
+}
+
+var errChannelPublicKey = errors.New("public_key must be a base64 ed25519 public key")
+
+// channelKeys returns the AppKey and PublicKey for a new credential: a fresh
+// secret for the SHA256 methods, the supplied public key for ED25519.
+func channelKeys(sigMethod, publicKey string) (string, string, error) {
+	if sigMethod == security.SIG_ED25519 {
+		if !security.ValidEd25519PublicKey(publicKey) {
+			return "", "", errChannelPublicKey
+		}
+		return "", publicKey, nil
+	}
+	secret, err := security.NewChannelSecret()
+	return secret, "", err
+}
+
+// channelKeysFailed reports a channelKeys error: a bad public key is the
+// caller's, anything else is ours.
+func channelKeysFailed(res *common.Response, appID string, err error) {
+	if errors.Is(err, errChannelPublicKey) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = err.Error()
+		return
+	}
+	log.Error("generate channel secret error: ", appID, err)
+	res.Code = codes.CODE_ERR_SECURITY
+	res.Msg = "generate channel key failed"
+}
+
+func ChannelList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	var list []model.SysChannel
+	if err := system.GetDb().Model(&model.SysChannel{}).Order("id asc").Find(&list).Error; err != nil {
+		log.Error("list channels error: ", err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load channels failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = list
+	c.JSON(http.StatusOK, res)
+}
+
+func ChannelCreate(c *gin.Context) {
+	var req ChannelCreateRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var count int64
+	db.Model(&model.SysChannel{}).Where("app_id = ? and chan = ?", req.AppID, req.Chan).Count(&count)
+	if count > 0 {
+		res.Code = codes.CODE_ERR_EXIST_OBJ
+		res.Msg = "app_id already exists on this channel"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	appKey, publicKey, err := channelKeys(req.SigMethod, req.PublicKey)
+	if err != nil {
+		channelKeysFailed(&res, req.AppID, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	now := time.Now()
+	channel := model.SysChannel{
+		AppID:      req.AppID,
+		AppKey:     appKey,
+		Status:     model.CHANNEL_STATUS_ACTIVE,
+		Chan:       req.Chan,
+		SigMethod:  req.SigMethod,
+		PublicKey:  publicKey,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if err := db.Create(&channel).Error; err != nil {
+		log.Error("create channel error: ", req.AppID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "create channel failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_CHANNEL_CREATE, 0, nil, channel)
+	channelChanged(channel.AppID)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = ChannelCredentials{Channel: channel, AppKey: appKey}
+	c.JSON(http.StatusOK, res)
+}
+
+// ChannelRotate issues new credentials. The old ones stay valid for the
+// overlap so clients can be redeployed before they stop working.
+func ChannelRotate(c *gin.Context) {
+	var req ChannelRotateRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	overlap := time.Duration(req.Overlap) * time.Second
+	if overlap <= 0 {
+		overlap = DEFAULT_KEY_OVERLAP
+	}
+	if overlap > MAX_KEY_OVERLAP {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "overlap is longer than 30 days"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	channel, ok := findChannel(req.ID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	appKey, publicKey, err := channelKeys(channel.SigMethod, req.PublicKey)
+	if err != nil {
+		channelKeysFailed(&res, channel.AppID, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	before := channel
+	now := time.Now()
+	channel.PrevAppKey, channel.PrevPublicKey = channel.AppKey, channel.PublicKey
+	channel.PrevExpire = now.Add(overlap)
+	channel.AppKey, channel.PublicKey = appKey, publicKey
+	channel.UpdateTime = now
+	err = system.GetDb().Model(&model.SysChannel{}).Where("id = ?", channel.ID).Updates(map[string]interface{}{
+		"app_key":         channel.AppKey,
+		"public_key":      channel.PublicKey,
+		"prev_app_key":    channel.PrevAppKey,
+		"prev_public_key": channel.PrevPublicKey,
+		"prev_expire":     channel.PrevExpire,
+		"update_time":     now,
+	}).Error
+	if err != nil {
+		log.Error("rotate channel error: ", channel.AppID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "rotate channel failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_CHANNEL_ROTATE, 0, before, channel)
+	channelChanged(channel.AppID)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = ChannelCredentials{Channel: channel, AppKey: appKey}
+	c.JSON(http.StatusOK, res)
+}
+
+// ChannelDisable stops a channel at once, old and new keys alike.
+func ChannelDisable(c *gin.Context) {
+	var req ChannelDisableRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	channel, ok := findChannel(req.ID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	before := channel
+	channel.Status = model.CHANNEL_STATUS_DISABLED
+	channel.UpdateTime = time.Now()
+	err := system.GetDb().Model(&model.SysChannel{}).Where("id = ?", channel.ID).
+		Updates(map[string]interface{}{"status": channel.Status, "update_time": channel.UpdateTime}).Error
+	if err != nil {
+		log.Error("disable channel error: ", channel.AppID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "disable channel failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_CHANNEL_DISABLE, 0, before, channel)
+	channelChanged(channel.AppID)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = channel
+	c.JSON(http.StatusOK, res)
+}
+
+func findChannel(id uint64, res *common.Response) (model.SysChannel, bool) {
+	var channel model.SysChannel
+	err := system.GetDb().Model(&model.SysChannel{}).Where("id = ?", id).First(&channel).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "channel not found"
+		} else {
+			log.Error("find channel error: ", id, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "find channel failed"
+		}
+		return channel, false
+	}
+	return channel, true
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/loginguard"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+)
+
+type LockoutClearRequest struct {
+	Scope   string `json:"scope" binding:"required,oneof=account ip"`
+	Subject string `json:"subject" binding:"required"`
+}
+
+// LockoutList pages through recorded lockouts, newest first, optionally
+// narrowed to one account name or IP with ?subject=.
+func LockoutList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	pageNo, _ := strconv.ParseInt(c.Query("pn"), 10, 64)
+	pageSize, _ := strconv.ParseInt(c.Query("ps"), 10, 64)
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := system.GetDb().Model(&model.LoginLockout{})
+	if subject := c.Query("subject"); len(subject) > 0 {
+		query = query.Where("subject = ?", subject)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []model.LoginLockout
+	err := query.Order("id DESC").
+		Offset(int((pageNo - 1) * pageSize)).
+		Limit(int(pageSize)).
+		Find(&list).Error
+	if err != nil {
+		log.Error("list lockouts error: ", err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load lockouts failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"list":        list,
+		"pn":          pageNo,
+		"ps":          pageSize,
+		"total":       total,
+		"total_pages": (total + pageSize - 1) / pageSize,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// LockoutClear unlocks an account name or IP before its lockout runs out.
+func LockoutClear(c *gin.Context) {
+	var req LockoutClearRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	subject := req.Subject
+	if req.Scope == loginguard.SCOPE_ACCOUNT {
+		subject = loginguard.Account(subject)
+	}
+	if err := loginguard.Clear(req.Scope, subject); err != nil {
+		log.Error("clear lockout error: ", req.Scope, subject, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "clear lockout failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,240 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/placement"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
+)
+
+// PlacementItemRequest creates an item, or updates it when ID is set. Band
+// is the CEFR band name, e.g. "B2".
+type PlacementItemRequest struct {
+	ID       uint64   `json:"id"`
+	Language string   `json:"language"`
+	Skill    string   `json:"skill"`
+	Band     string   `json:"band"`
+	Prompt   string   `json:"prompt"`
+	Media    string   `json:"media"`
+	Options  []string `json:"options"`
+	Answer   int      `json:"answer"`
+}
+
+type PlacementItemDeleteRequest struct {
+	ID uint64 `json:"id" binding:"required"`
+}
+
+// validatePlacementItem checks the request and turns it into the stored
+// fields of item.
+func validatePlacementItem(req *PlacementItemRequest, item *model.PlacementItem, res *common.Response) bool {
+	var errs validation.Errors
+	if lang, err := validation.Language(req.Language); errs.Check("language", err) {
+		item.Language = lang
+	}
+	if placement.ValidSkill(req.Skill) {
+		item.Skill = req.Skill
+	} else {
+		errs.Add("skill", validation.CODE_INVALID, "skill must be one of "+strings.Join(placement.SKILLS, ", "))
+	}
+	if level, ok := placement.Level(req.Band); ok {
+		item.Level = level
+	} else {
+		errs.Add("band", validation.CODE_INVALID, "band must be one of "+strings.Join(placement.BANDS, ", "))
+	}
+	item.Prompt = strings.TrimSpace(req.Prompt)
+	if len(item.Prompt) == 0 {
+		errs.Add("prompt", validation.CODE_REQUIRED, "prompt is required")
+	}
+	item.Media = ""
+	if len(req.Media) > 0 {
+		if media, err := validation.URL(req.Media); errs.Check("media", err) {
+			item.Media = media
+		}
+	}
+	if len(req.Options) < 2 {
+		errs.Add("options", validation.CODE_REQUIRED, "at least two options are required")
+	} else if req.Answer < 0 || req.Answer >= len(req.Options) {
+		errs.Add("answer", validation.CODE_INVALID, "answer must be the index of an option")
+	}
+	if !common.InvalidFields(res, errs) {
+		return false
+	}
+	options, _ := json.Marshal(req.Options)
+	item.Options = string(options)
+	item.Answer = req.Answer
+	return true
+}
+
+// PlacementItemList pages through the item bank, filtered by ?language=,
+// ?skill= and ?band=.
+func PlacementItemList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	pageNo, _ := strconv.ParseInt(c.Query("pn"), 10, 64)
+	pageSize, _ := strconv.ParseInt(c.Query("ps"), 10, 64)
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := system.GetDb().Model(&model.PlacementItem{}).Where("flag != ?", -1)
+	if language := c.Query("language"); len(language) > 0 {
+		query = query.Where("language = ?", language)
+	}
+	if skill := c.Query("skill"); len(skill) > 0 {
+		query = query.Where("skill = ?", skill)
+	}
+	if level, ok := placement.Level(c.Query("band")); ok {
+		query = query.Where("level = ?", level)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []model.PlacementItem
+	err := query.Order("id DESC").
+		Offset(int((pageNo - 1) * pageSize)).
+		Limit(int(pageSize)).
+		Find(&list).Error
+	if err != nil {
+		log.Error("list placement items error: ", err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load placement items failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"list":        list,
+		"pn":          pageNo,
+		"ps":          pageSize,
+		"total":       total,
+		"total_pages": (total + pageSize - 1) / pageSize,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func PlacementItemSave(c *gin.Context) {
+	var req PlacementItemRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var item model.PlacementItem
+	if req.ID > 0 {
+		db.Model(&model.PlacementItem{}).Where("id = ? and flag != ?", req.ID, -1).First(&item)
+		if item.ID == 0 {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "placement item not found"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	before := item
+	if !validatePlacementItem(&req, &item, &res) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	now := time.Now()
+	item.UpdateTime = now
+	var err error
+	if item.ID > 0 {
+		err = db.Model(&model.PlacementItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+			"language":    item.Language,
+			"skill":       item.Skill,
+			"level":       item.Level,
+			"prompt":      item.Prompt,
+			"media":       item.Media,
+			"options":     item.Options,
+			"answer":      item.Answer,
+			"update_time": now,
+		}).Error
+	} else {
+		item.AddTime = now
+		err = db.Create(&item).Error
+	}
+	if err != nil {
+		log.Error("save placement item error: ", item.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "save placement item failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if before.ID > 0 {
+		audit.Record(c, audit.ACTION_PLACEMENT_ITEM, 0, before, item)
+	} else {
+		audit.Record(c, audit.ACTION_PLACEMENT_ITEM, 0, nil, item)
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = item
+	c.JSON(http.StatusOK, res)
+}
+
+// PlacementItemDelete retires an item. Finished tests keep their answers
+// to it.
+func PlacementItemDelete(c *gin.Context) {
+	var req PlacementItemDeleteRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var item model.PlacementItem
+	db.Model(&model.PlacementItem{}).Where("id = ? and flag != ?", req.ID, -1).First(&item)
+	if item.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "placement item not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	before := item
+	item.Flag = -1
+	item.UpdateTime = time.Now()
+	err := db.Model(&model.PlacementItem{}).Where("id = ?", item.ID).
+		Updates(map[string]interface{}{"flag": -1, "update_time": item.UpdateTime}).Error
+	if err != nil {
+		log.Error("delete placement item error: ", item.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "delete placement item failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_PLACEMENT_ITEM, 0, before, item)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+type UserRoleRequest struct {
+	UserNo string `json:"user_no" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+	Revoke bool   `json:"revoke"`
+}
+
+type TeacherLinkRequest struct {
+	TeacherID uint64 `json:"teacher_id" binding:"required"`
+	UserNo    string `json:"user_no" binding:"required"`
+}
+
+// UserRoleUpdate grants or revokes a role. Revoking signs the user out
+// everywhere so the role does not outlive the current access tokens.
+func UserRoleUpdate(c *gin.Context) {
+	var req UserRoleRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if !security.ValidRole(req.Role) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown role"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userInfo, ok := findUser(req.UserNo, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var err error
+	if req.Revoke {
+		err = revokeRole(userInfo.ID, req.Role)
+		if err == nil {
+			if err := session.RevokeAll(userInfo.ID); err != nil {
+				log.Error("revoke sessions after role change error: ", userInfo.ID, err)
+			}
+		}
+	} else {
+		err = grantRole(system.GetDb(), userInfo.ID, req.Role)
+	}
+	if err != nil {
+		log.Error("update user role error: ", userInfo.ID, req.Role, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "update role failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+// TeacherLink attaches a teacher_info record to a login account and grants it
+// the teacher role, so the teacher can use the teacher portal.
+func TeacherLink(c *gin.Context) {
+	var req TeacherLinkRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userInfo, ok := findUser(req.UserNo, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var teacher model.Teacher
+	db.Model(&model.Teacher{}).Where("id = ? and flag != ?", req.TeacherID, -1).First(&teacher)
+	if teacher.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "teacher not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if teacher.UserID > 0 && teacher.UserID != userInfo.ID {
+		res.Code = codes.CODE_ERR_EXIST_OBJ
+		res.Msg = "teacher already linked to another account"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Teacher{}).Where("id = ?", teacher.ID).
+			Updates(map[string]interface{}{"user_id": userInfo.ID, "update_time": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		return grantRole(tx, userInfo.ID, security.ROLE_TEACHER)
+	})
+	if err != nil {
+		log.Error("link teacher error: ", teacher.ID, userInfo.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "link teacher failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+func findUser(userNo string, res *common.Response) (*model.UserInfo, bool) {
+	var userInfo model.UserInfo
+	err := system.GetDb().Model(&model.UserInfo{}).Where("user_no = ?", userNo).First(&userInfo).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "user not found"
+		} else {
+			log.Error("find user error: ", userNo, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "find user failed"
+		}
+		return nil, false
+	}
+	return &userInfo, true
+}
+
+func grantRole(db *gorm.DB, userID uint64, role string) error {
+	var count int64
+	err := db.Model(&model.UserRole{}).Where("user_id = ? and role = ? and flag != ?", userID, role, -1).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+	return db.Save(&model.UserRole{UserID: userID, Role: role, AddTime: time.Now()}).Error
+}
+
+func revokeRole(userID uint64, role string) error {
+	return system.GetDb().Model(&model.UserRole{}).
+		Where("user_id = ? and role = ? and flag != ?", userID, role, -1).
+		Update("flag", -1).Error
+}
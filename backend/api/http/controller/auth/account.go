@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/account"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+)
+
+type AccountDeleteRequest struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"` // the account email, for accounts without a password
+}
+
+// AccountExport returns the caller's personal data, as JSON by default or as
+// a ZIP download with ?format=zip.
+func AccountExport(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	first, err := system.Throttle(fmt.Sprintf("lb:account:export:%d", userID), time.Minute)
+	if err != nil {
+		log.Error("account export throttle error: ", userID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "export failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if !first {
+		res.Code = codes.CODE_ERR_REPEAT
+		res.Msg = "please wait a minute before exporting again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	export, err := account.Collect(userID)
+	if err != nil {
+		log.Error("collect account export error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "export failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if c.Query("format") == "zip" {
+		var buf bytes.Buffer
+		if err := export.WriteZip(&buf); err != nil {
+			log.Error("write account export zip error: ", userID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "export failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		filename := fmt.Sprintf("langbridge-%s-%s.zip", export.User.UserNo, export.ExportTime.Format("20060102"))
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = export
+	c.JSON(http.StatusOK, res)
+}
+
+// AccountDelete schedules the account for deletion after the grace period.
+// The caller confirms with the password, or with the account email when the
+// account only signs in through a provider.
+func AccountDelete(c *gin.Context) {
+	var req AccountDeleteRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_TX
+		res.Msg = "please login"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	var confirmed bool
+	if len(userInfo.Password) > 0 {
+		confirmed, _ = security.VerifyPassword(req.Password, userInfo.Password)
+	} else {
+		confirmed = len(req.Confirm) > 0 && strings.EqualFold(strings.TrimSpace(req.Confirm), userInfo.Email)
+	}
+	if !confirmed {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "confirmation does not match"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	deletion, err := account.RequestDeletion(userInfo)
+	if err != nil {
+		log.Error("request account deletion error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "delete account failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = deletion
+	c.JSON(http.StatusOK, res)
+}
+
+func AccountDeleteCancel(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if err := account.CancelDeletion(userID); err != nil {
+		if errors.Is(err, account.ErrNoPendingDeletion) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "no pending deletion"
+		} else {
+			log.Error("cancel account deletion error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "cancel deletion failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
@@ -9,10 +9,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
 	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/family"
 	"github.com/langbridge/backend/log"
 	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/notify"
 	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/timezone"
 	"github.com/langbridge/backend/utils"
 	"gorm.io/gorm"
 )
@@ -26,15 +30,102 @@ type CourseSelectTimeSlot struct {
 type CourseConfirmRequest struct {
 	CourseID  uint64                 `json:"course_id"`
 	TeacherID uint64                 `json:"teacher_id"`
+	MemberID  uint64                 `json:"member_id"` // book for a family member, 0 for yourself
 	StartDate string                 `json:"start_date"`
 	EndDate   string                 `json:"end_date"`
 	TimeSlots []CourseSelectTimeSlot `json:"time_slots"`
+	Zone      string                 `json:"zone"` // lesson (default), local or utc: the zone of the dates and slots
 }
 
 type internalComputeBookDatetime struct {
-	LessonDate string
-	StartTime  string
-	EndTime    string
+	Start time.Time
+	End   time.Time
+}
+
+// learner narrows course and booking queries to one person on the account.
+// Without ?member_id= they cover everyone: the holder and all members.
+type learner struct {
+	set      bool
+	memberID uint64
+}
+
+func (l learner) where(db *gorm.DB, column string) *gorm.DB {
+	if !l.set {
+		return db
+	}
+	return db.Where(column+" = ?", l.memberID)
+}
+
+// bookingAccount returns the account whose courses and bookings the caller
+// works with. A member signed in with their own login acts on the guardian's
+// account and is pinned to their member id.
+func bookingAccount(userID int64) (int64, learner) {
+	if member, ok := family.Pinned(uint64(userID)); ok {
+		return int64(member.UserID), learner{set: true, memberID: member.ID}
+	}
+	return userID, learner{}
+}
+
+// learnerQuery resolves the account and learner a listing covers, from the
+// caller and an optional ?member_id=.
+func learnerQuery(c *gin.Context, db *gorm.DB, userID int64, res *common.Response) (int64, learner, bool) {
+	accountID, pinned := bookingAccount(userID)
+	if pinned.set {
+		return accountID, pinned, true
+	}
+	memberID, ok := queryMemberID(c, res)
+	if !ok {
+		return userID, learner{}, false
+	}
+	if memberID == 0 {
+		return userID, learner{}, true
+	}
+	if !checkMember(db, userID, memberID, res) {
+		return userID, learner{}, false
+	}
+	return userID, learner{set: true, memberID: memberID}, true
+}
+
+// queryMemberID reads ?member_id=, 0 when absent. Anything else that is not
+// a number is refused rather than read as the account holder.
+func queryMemberID(c *gin.Context, res *common.Response) (uint64, bool) {
+	v, ok := c.GetQuery("member_id")
+	if !ok || len(v) == 0 {
+		return 0, true
+	}
+	memberID, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid member id"
+		return 0, false
+	}
+	return memberID, true
+}
+
+// bookingLearner resolves the account and member a new course or booking is
+// made for. A member's own login always books for that member.
+func bookingLearner(db *gorm.DB, userID int64, memberID uint64, res *common.Response) (int64, uint64, bool) {
+	accountID, pinned := bookingAccount(userID)
+	if pinned.set {
+		return accountID, pinned.memberID, true
+	}
+	return userID, memberID, checkMember(db, userID, memberID, res)
+}
+
+// checkMember makes sure memberID is one of the caller's members; 0 stands
+// for the caller and always passes.
+func checkMember(db *gorm.DB, userID int64, memberID uint64, res *common.Response) bool {
+	if memberID == 0 {
+		return true
+	}
+	var count int64
+	db.Model(&model.UserMember{}).Where("id = ? and user_id = ? and flag != ?", memberID, userID, -1).Count(&count)
+	if count == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "member not found"
+		return false
+	}
+	return true
 }
 
 func CourseJoin(c *gin.Context) {
@@ -59,8 +150,18 @@ func CourseJoin(c *gin.Context) {
 	}
 
 	courseId, _ := strconv.ParseInt(c.Query("course_id"), 10, 64)
+	memberID, ok := queryMemberID(c, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	db := system.GetDb()
+	userID, memberID, ok = bookingLearner(db, userID, memberID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	var course model.CourseInfo
 
@@ -73,12 +174,13 @@ func CourseJoin(c *gin.Context) {
 	}
 
 	var userCourseSelected model.UserCourse
-	err = db.Model(&model.UserCourse{}).Where("user_id = ? and course_id = ? and flag != ?", userID, course.ID, -1).First(&userCourseSelected).Error
+	err = db.Model(&model.UserCourse{}).Where("user_id = ? and member_id = ? and course_id = ? and flag != ?", userID, memberID, course.ID, -1).First(&userCourseSelected).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			userCourseSelected.AddTime = time.Now()
 			userCourseSelected.CourseID = course.ID
 			userCourseSelected.UserID = uint64(userID)
+			userCourseSelected.MemberID = memberID
 			userCourseSelected.Flag = 0
 			userCourseSelected.Status = "00"
 			err = db.Model(&model.UserCourse{}).Save(&userCourseSelected).Error
@@ -122,14 +224,20 @@ func CourseList(c *gin.Context) {
 	}
 
 	db := system.GetDb()
+	userID, who, ok := learnerQuery(c, db, userID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	var result []model.UserCourseWithCourse
 
-	err = db.Table("user_course AS uc").
+	err = who.where(db.Table("user_course AS uc"), "uc.member_id").
 		Joins("JOIN course_info AS c ON c.id = uc.course_id").
 		Select(`
 		uc.id AS uc_id,
 		uc.user_id,
+		uc.member_id,
 		uc.course_id,
 		uc.status AS uc_status,
 		uc.add_time AS uc_add_time,
@@ -186,9 +294,31 @@ func CourseConfirm(c *gin.Context) {
 		return
 	}
 
+	// slots are picked from the teacher's templates, which are in the
+	// lesson zone, unless the client says it converted them
+	if len(req.Zone) == 0 {
+		req.Zone = ZONE_LESSON
+	}
+	db := system.GetDb()
+	loc, ok := requestZone(db, userID, req.Zone, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	layout := "2006-01-02"
-	start, _ := time.Parse(layout, req.StartDate)
-	end, _ := time.Parse(layout, req.EndDate)
+	start, err1 := time.ParseInLocation(layout, req.StartDate, loc)
+	end, err2 := time.ParseInLocation(layout, req.EndDate, loc)
+	if err1 != nil || err2 != nil || end.Before(start) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "start_date and end_date must be in yyyy-MM-dd format"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// slots are wall clock times in loc; lessons are compared and stored as
+	// instants converted to the lesson zone
+	store := timezone.Lesson()
 	var internalResult []internalComputeBookDatetime
 	var allDate []string
 
@@ -197,14 +327,19 @@ func CourseConfirm(c *gin.Context) {
 		if weekday == 0 {
 			weekday = 7 // Sunday fix
 		}
-		allDate = append(allDate, d.Format("2006-01-02"))
 		for _, slot := range req.TimeSlots {
 			if slot.WeekDay == weekday {
-				internalResult = append(internalResult, internalComputeBookDatetime{
-					LessonDate: d.Format(layout),
-					StartTime:  slot.StartTime,
-					EndTime:    slot.EndTime,
-				})
+				lessonStart, lessonEnd, err := utils.LessonSpan(d, slot.StartTime, slot.EndTime, loc)
+				if err != nil {
+					res.Code = codes.CODE_ERR_BAD_PARAMS
+					res.Msg = "time slots must be in HH:mm format"
+					c.JSON(http.StatusOK, res)
+					return
+				}
+				internalResult = append(internalResult, internalComputeBookDatetime{Start: lessonStart, End: lessonEnd})
+				storeDate, _ := utils.WallClock(lessonStart, store)
+				// a lesson stored on the day before may run past midnight
+				allDate = append(allDate, storeDate.Format(layout), storeDate.AddDate(0, 0, -1).Format(layout))
 			}
 		}
 	}
@@ -216,7 +351,11 @@ func CourseConfirm(c *gin.Context) {
 		return
 	}
 
-	db := system.GetDb()
+	userID, memberID, ok := bookingLearner(db, userID, req.MemberID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	var existResult []model.CourseBookTrans
 	// query can book?
@@ -228,25 +367,18 @@ func CourseConfirm(c *gin.Context) {
 		log.Error("error query result set", err)
 	}
 
-	if len(existResult) > 0 {
-		for _, want := range internalResult {
-			for _, exist := range existResult {
-				targetDate := exist.LessonDate.Format("2006-01-02")
-				if want.LessonDate == targetDate {
-					// parse time string to time.Time (only HH:mm)
-					newStart, _ := time.Parse("15:04", want.StartTime)
-					newEnd, _ := time.Parse("15:04", want.EndTime)
-					existStart, _ := time.Parse("15:04:00", exist.StartTime)
-					existEnd, _ := time.Parse("15:04:00", exist.EndTime)
-
-					hasConflict := newStart.Before(existEnd) && newEnd.After(existStart)
-					if hasConflict {
-						res.Code = codes.CODE_BOOKING_CONFLICT
-						res.Msg = fmt.Sprintf("booking conflict on %s %s–%s", want.LessonDate, want.StartTime, want.EndTime)
-						c.JSON(http.StatusOK, res)
-						return
-					}
-				}
+	for _, want := range internalResult {
+		for _, exist := range existResult {
+			existStart, existEnd, err := utils.LessonSpan(exist.LessonDate, exist.StartTime, exist.EndTime, store)
+			if err != nil {
+				continue
+			}
+			if want.Start.Before(existEnd) && want.End.After(existStart) {
+				res.Code = codes.CODE_BOOKING_CONFLICT
+				res.Msg = fmt.Sprintf("booking conflict on %s %s–%s", want.Start.In(loc).Format(layout),
+					want.Start.In(loc).Format("15:04"), want.End.In(loc).Format("15:04"))
+				c.JSON(http.StatusOK, res)
+				return
 			}
 		}
 	}
@@ -258,24 +390,42 @@ func CourseConfirm(c *gin.Context) {
 	var saveResult []model.CourseBookTrans
 
 	for _, r := range internalResult {
-		ledate, err := time.Parse("2025-01-02", r.LessonDate)
-		if err != nil {
-			saveResult = append(saveResult, model.CourseBookTrans{
-				BookingNo:  bookNo,
-				TeacherID:  req.TeacherID,
-				CourseID:   req.CourseID,
-				UserID:     uint64(userID),
-				LessonDate: ledate,
-				StartTime:  r.StartTime,
-				EndTime:    r.EndTime,
-				Status:     "000",
-				AddTime:    auTime,
-				UpdateTime: auTime,
-			})
-		}
+		lessonDate, startTime := utils.WallClock(r.Start, store)
+		_, endTime := utils.WallClock(r.End, store)
+		saveResult = append(saveResult, model.CourseBookTrans{
+			BookingNo:  bookNo,
+			TeacherID:  req.TeacherID,
+			CourseID:   req.CourseID,
+			UserID:     uint64(userID),
+			MemberID:   memberID,
+			LessonDate: dbDate(lessonDate),
+			StartTime:  startTime,
+			EndTime:    endTime,
+			Status:     "000",
+			AddTime:    auTime,
+			UpdateTime: auTime,
+		})
+	}
+
+	// all lessons of the booking or none
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&saveResult, 200).Error
+	})
+	if err != nil {
+		log.Error("[Course] save booking error: ", bookNo, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "booking failed"
+		c.JSON(http.StatusOK, res)
+		return
 	}
-
-	db.CreateInBatches(&saveResult, 200)
+	audit.Record(c, audit.ACTION_BOOKING_CONFIRM, uint64(userID), nil, gin.H{
+		"booking_no": bookNo,
+		"teacher_id": req.TeacherID,
+		"course_id":  req.CourseID,
+		"member_id":  memberID,
+		"lessons":    len(saveResult),
+	})
+	go notify.BookingConfirmed(saveResult)
 
 	res.Code = codes.CODE_SUCCESS
 	res.Msg = "success"
@@ -315,19 +465,30 @@ func CourseTimeList(c *gin.Context) {
 	}
 
 	db := system.GetDb()
+	loc, ok := requestZone(db, userID, c.Query("zone"), &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, who, ok := learnerQuery(c, db, userID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	var total int64
-	db.Model(&model.CourseBookTrans{}).
+	who.where(db.Model(&model.CourseBookTrans{}), "member_id").
 		Where("user_id = ?", userID).
 		Count(&total)
 
 	var result []model.CourseBookWithJoin
 
-	err = db.Table("course_book_trans").
+	err = who.where(db.Table("course_book_trans"), "course_book_trans.member_id").
 		Joins("LEFT JOIN teacher_info ON course_book_trans.teacher_id = teacher_info.id").
 		Joins("LEFT JOIN course_info ON course_book_trans.course_id = course_info.id").
+		Joins("LEFT JOIN user_member ON course_book_trans.member_id = user_member.id").
 		Where("course_book_trans.user_id = ?", userID).
-		Select("course_book_trans.*, teacher_info.name AS teacher_name, course_info.name AS course_name").
+		Select("course_book_trans.*, teacher_info.name AS teacher_name, course_info.name AS course_name, user_member.name AS member_name").
 		Order("lesson_date, start_time ASC").
 		Offset(int((pageNo - 1)) * int(pageSize)).
 		Limit(int(pageSize)).
@@ -335,6 +496,9 @@ func CourseTimeList(c *gin.Context) {
 	if err != nil {
 		log.Error(err)
 	}
+	for i := range result {
+		renderLesson(&result[i], loc)
+	}
 
 	totalPages := (total + pageSize - 1) / pageSize
 
@@ -371,12 +535,19 @@ func CourseTimeRange(c *gin.Context) {
 		return
 	}
 
+	db := system.GetDb()
+	loc, ok := requestZone(db, userID, c.Query("zone"), &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	layout := "2006-01-02"
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
 
-	startDate, err1 := time.Parse(layout, startDateStr)
-	endDate, err2 := time.Parse(layout, endDateStr)
+	startDate, err1 := time.ParseInLocation(layout, startDateStr, loc)
+	endDate, err2 := time.ParseInLocation(layout, endDateStr, loc)
 
 	if err1 != nil || startDate.Format(layout) != startDateStr {
 		res.Code = codes.CODE_ERR_BAD_PARAMS
@@ -392,26 +563,41 @@ func CourseTimeRange(c *gin.Context) {
 		return
 	}
 
-	db := system.GetDb()
+	userID, who, ok := learnerQuery(c, db, userID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
-	var total int64
-	db.Model(&model.CourseBookTrans{}).
-		Where("user_id = ? and lesson_date >= ? and lesson_date <= ?", userID, startDate, endDate).
-		Count(&total)
+	// the range covers whole days in loc; widen it by a day in the lesson
+	// zone and keep the lessons that start inside it
+	rangeStart, rangeEnd := startDate, endDate.AddDate(0, 0, 1)
+	storeStart, _ := utils.WallClock(rangeStart, timezone.Lesson())
+	storeEnd, _ := utils.WallClock(rangeEnd, timezone.Lesson())
+	fromStr, toStr := storeStart.AddDate(0, 0, -1).Format(layout), storeEnd.Format(layout)
 
-	var result []model.CourseBookWithJoin
+	var stored []model.CourseBookWithJoin
 
-	err = db.Table("course_book_trans").
+	err = who.where(db.Table("course_book_trans"), "course_book_trans.member_id").
 		Joins("LEFT JOIN teacher_info ON course_book_trans.teacher_id = teacher_info.id").
 		Joins("LEFT JOIN course_info ON course_book_trans.course_id = course_info.id").
-		Where("course_book_trans.user_id = ? and course_book_trans.lesson_date >= ? and course_book_trans.lesson_date <= ?", userID, startDateStr, endDateStr).
-		Select("course_book_trans.*, teacher_info.name AS teacher_name, course_info.name AS course_name").
+		Joins("LEFT JOIN user_member ON course_book_trans.member_id = user_member.id").
+		Where("course_book_trans.user_id = ? and course_book_trans.lesson_date >= ? and course_book_trans.lesson_date <= ?", userID, fromStr, toStr).
+		Select("course_book_trans.*, teacher_info.name AS teacher_name, course_info.name AS course_name, user_member.name AS member_name").
 		Order("lesson_date, start_time ASC").
-		Scan(&result).Error
+		Scan(&stored).Error
 	if err != nil {
 		log.Error(err)
 	}
 
+	result := []model.CourseBookWithJoin{}
+	for _, b := range stored {
+		renderLesson(&b, loc)
+		if !b.StartAt.Before(rangeStart) && b.StartAt.Before(rangeEnd) {
+			result = append(result, b)
+		}
+	}
+
 	res.Code = codes.CODE_SUCCESS
 	res.Msg = "success"
 	res.Data = result
@@ -450,8 +636,14 @@ func CourseGetMeetingInfo(c *gin.Context) {
 	}
 
 	db := system.GetDb()
+	loc, ok := requestZone(db, userID, c.Query("zone"), &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, who := bookingAccount(userID)
 	var bookTran model.CourseBookTrans
-	err = db.Model(&model.CourseBookTrans{}).Where("id = ? and user_id = ?", btid, userID).First(&bookTran).Error
+	err = who.where(db.Model(&model.CourseBookTrans{}), "member_id").Where("id = ? and user_id = ?", btid, userID).First(&bookTran).Error
 
 	if err != nil {
 		log.Error("fetch course meeting error", err)
@@ -480,21 +672,26 @@ func CourseGetMeetingInfo(c *gin.Context) {
 	var teacherInfo model.Teacher
 	db.Model(&model.CourseInfo{}).Where("id = ?", bookTran.CourseID).First(&courseInfo)
 	db.Model(&model.Teacher{}).Where("id = ?", bookTran.TeacherID).First(&teacherInfo)
+	lesson := model.CourseBookWithJoin{CourseBookTrans: bookTran}
+	renderLesson(&lesson, loc)
 
 	res.Code = codes.CODE_SUCCESS
 	res.Msg = "success"
 	res.Data = struct {
-		MeetingURI    string `json:"meeting_uri"`
-		BookID        uint64 `json:"book_id"`
-		CourseName    string `json:"course_name"`
-		CourseDetail  string `json:"course_detail"`
-		CourseID      uint64 `json:"course_id"`
-		TeacherName   string `json:"teacher_name"`
-		TeacherID     uint64 `json:"teacher_id"`
-		TeacherDetail string `json:"teacher_detail"`
-		LessonDate    string `json:"lesson_date"`
-		StartTime     string `json:"start_time"`
-		EndTime       string `json:"end_time"`
+		MeetingURI    string    `json:"meeting_uri"`
+		BookID        uint64    `json:"book_id"`
+		CourseName    string    `json:"course_name"`
+		CourseDetail  string    `json:"course_detail"`
+		CourseID      uint64    `json:"course_id"`
+		TeacherName   string    `json:"teacher_name"`
+		TeacherID     uint64    `json:"teacher_id"`
+		TeacherDetail string    `json:"teacher_detail"`
+		LessonDate    string    `json:"lesson_date"`
+		StartTime     string    `json:"start_time"`
+		EndTime       string    `json:"end_time"`
+		StartAt       time.Time `json:"start_at"`
+		EndAt         time.Time `json:"end_at"`
+		Timezone      string    `json:"timezone"`
 	}{
 		MeetingURI:    roomURI,
 		BookID:        bookTran.ID,
@@ -504,9 +701,12 @@ func CourseGetMeetingInfo(c *gin.Context) {
 		TeacherName:   teacherInfo.Name,
 		TeacherID:     teacherInfo.ID,
 		TeacherDetail: teacherInfo.Detail,
-		LessonDate:    bookTran.LessonDate.Format("2006-01-02"),
-		StartTime:     bookTran.StartTime,
-		EndTime:       bookTran.EndTime,
+		LessonDate:    lesson.LessonDate.Format("2006-01-02"),
+		StartTime:     lesson.StartTime,
+		EndTime:       lesson.EndTime,
+		StartAt:       lesson.StartAt,
+		EndAt:         lesson.EndAt,
+		Timezone:      lesson.Timezone,
 	}
 	c.JSON(http.StatusOK, res)
 }
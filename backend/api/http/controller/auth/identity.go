@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+)
+
+type UnlinkIdentityRequest struct {
+	Provider string `json:"provider" binding:"required"`
+}
+
+func IdentityList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var identities []model.UserIdentity
+	err := system.GetDb().Model(&model.UserIdentity{}).Where("user_id = ?", userID).Order("id asc").Find(&identities).Error
+	if err != nil {
+		log.Error("list identities error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load identities failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = identities
+	c.JSON(http.StatusOK, res)
+}
+
+// IdentityUnlink removes a social login, unless it is the only way left to
+// sign in to the account.
+func IdentityUnlink(c *gin.Context) {
+	var req UnlinkIdentityRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var userInfo model.UserInfo
+	db.Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	var others int64
+	db.Model(&model.UserIdentity{}).Where("user_id = ? and provider != ?", userID, req.Provider).Count(&others)
+	if len(userInfo.Password) == 0 && others == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "set a password before removing your last sign-in method"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	result := db.Where("user_id = ? and provider = ?", userID, req.Provider).Delete(&model.UserIdentity{})
+	if result.Error != nil {
+		log.Error("unlink identity error: ", userID, req.Provider, result.Error)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "unlink account failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if result.RowsAffected == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "provider is not linked"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
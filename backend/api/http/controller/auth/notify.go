@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/notify"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
+)
+
+type NotifyPreferenceItem struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NotifyPreferenceRequest changes only what it names: omitted events and
+// channels keep their setting, and quiet hours are left alone unless
+// QuietStart or QuietEnd is sent. Sending both as "" turns quiet hours off.
+type NotifyPreferenceRequest struct {
+	Preferences []NotifyPreferenceItem `json:"preferences"`
+	QuietStart  *string                `json:"quiet_start"`
+	QuietEnd    *string                `json:"quiet_end"`
+}
+
+type NotifyReadRequest struct {
+	IDs []uint64 `json:"ids"`
+	All bool     `json:"all"`
+}
+
+func NotifyPreferences(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	prefs, err := notify.Load(userID)
+	if err != nil {
+		log.Error("load notify preferences error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load preferences failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = prefs
+	c.JSON(http.StatusOK, res)
+}
+
+func NotifyPreferencesUpdate(c *gin.Context) {
+	var req NotifyPreferenceRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var errs validation.Errors
+	for i, p := range req.Preferences {
+		if !notify.ValidEvent(p.Event) {
+			errs.Add("preferences["+strconv.Itoa(i)+"].event", validation.CODE_INVALID, "unknown event")
+		}
+		if !notify.ValidChannel(p.Channel) {
+			errs.Add("preferences["+strconv.Itoa(i)+"].channel", validation.CODE_INVALID, "unknown channel")
+		}
+	}
+	var quietStart, quietEnd string
+	setQuiet := req.QuietStart != nil || req.QuietEnd != nil
+	if setQuiet {
+		if req.QuietStart != nil {
+			quietStart = *req.QuietStart
+		}
+		if req.QuietEnd != nil {
+			quietEnd = *req.QuietEnd
+		}
+		if len(quietStart) > 0 || len(quietEnd) > 0 {
+			start, err := validation.Clock(quietStart)
+			if errs.Check("quiet_start", err) {
+				quietStart = start
+			}
+			end, err := validation.Clock(quietEnd)
+			if errs.Check("quiet_end", err) {
+				quietEnd = end
+			}
+			if err == nil && quietStart == quietEnd {
+				errs.Add("quiet_end", validation.CODE_INVALID, "quiet hours must not start and end at the same time")
+			}
+		}
+	}
+	if !common.InvalidFields(&res, errs) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	for _, p := range req.Preferences {
+		if err := notify.SetPreference(db, userID, p.Event, p.Channel, p.Enabled); err != nil {
+			log.Error("save notify preference error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save preferences failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	if setQuiet {
+		if err := notify.SetQuietHours(db, userID, quietStart, quietEnd); err != nil {
+			log.Error("save quiet hours error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save quiet hours failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+
+	prefs, err := notify.Load(userID)
+	if err != nil {
+		log.Error("load notify preferences error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load preferences failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = prefs
+	c.JSON(http.StatusOK, res)
+}
+
+// NotifyList pages through the in-app inbox, newest first.
+func NotifyList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	pageNo, _ := strconv.ParseInt(c.Query("pn"), 10, 64)
+	pageSize, _ := strconv.ParseInt(c.Query("ps"), 10, 64)
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	db := system.GetDb()
+	var total, unread int64
+	db.Model(&model.Notification{}).Where("user_id = ?", userID).Count(&total)
+	db.Model(&model.Notification{}).Where("user_id = ? and read_time IS NULL", userID).Count(&unread)
+
+	var list []model.Notification
+	err := db.Model(&model.Notification{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Offset(int((pageNo - 1) * pageSize)).
+		Limit(int(pageSize)).
+		Find(&list).Error
+	if err != nil {
+		log.Error("list notifications error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load notifications failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"list":        list,
+		"unread":      unread,
+		"pn":          pageNo,
+		"ps":          pageSize,
+		"total":       total,
+		"total_pages": (total + pageSize - 1) / pageSize,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func NotifyRead(c *gin.Context) {
+	var req NotifyReadRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if !req.All && len(req.IDs) == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "ids or all is required"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	query := system.GetDb().Model(&model.Notification{}).Where("user_id = ? and read_time IS NULL", userID)
+	if !req.All {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	if err := query.Update("read_time", time.Now()).Error; err != nil {
+		log.Error("mark notifications read error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "mark read failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
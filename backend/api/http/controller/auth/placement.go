@@ -0,0 +1,437 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/placement"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
+	"gorm.io/gorm"
+)
+
+// PLACEMENT_SESSION_TTL is how long a learner has to finish a test they
+// started.
+const PLACEMENT_SESSION_TTL = 2 * time.Hour
+
+type PlacementStartRequest struct {
+	Language string `json:"language" binding:"required"`
+	MemberID uint64 `json:"member_id"` // the member taking the test, 0 for the caller
+}
+
+type PlacementAnswerRequest struct {
+	SessionID uint64 `json:"session_id" binding:"required"`
+	ItemID    uint64 `json:"item_id" binding:"required"`
+	Choice    int    `json:"choice"` // index into the question's options
+}
+
+// PlacementQuestion is an item as the learner sees it, without the answer
+// or its band.
+type PlacementQuestion struct {
+	ID      uint64   `json:"id"`
+	Number  int      `json:"number"` // 1-based position in the test
+	Skill   string   `json:"skill"`
+	Prompt  string   `json:"prompt"`
+	Media   string   `json:"media,omitempty"`
+	Options []string `json:"options"`
+}
+
+type PlacementState struct {
+	SessionID uint64             `json:"session_id"`
+	Finished  bool               `json:"finished"`
+	Question  *PlacementQuestion `json:"question,omitempty"`
+	Result    *placement.Result  `json:"result,omitempty"`
+	MaxItems  int                `json:"max_items"`
+}
+
+// nextPlacementItem draws an unasked item at the step's band, preferring the
+// step's skill. It returns a zero item when the bank has nothing left there.
+func nextPlacementItem(db *gorm.DB, language string, step placement.Step, answers []placement.Answer) (model.PlacementItem, error) {
+	asked := []uint64{0}
+	for _, a := range answers {
+		asked = append(asked, a.ItemID)
+	}
+	var item model.PlacementItem
+	query := func(level int) *gorm.DB {
+		return db.Model(&model.PlacementItem{}).
+			Where("language = ? and level = ? and flag != ? and id NOT IN ?", language, level, -1, asked)
+	}
+	// when the band has nothing left to ask, the nearest band that does
+	// stands in, so a thin bank does not end the test early
+	levels := []int{step.Level}
+	for d := 1; d < len(placement.BANDS); d++ {
+		levels = append(levels, step.Level+d, step.Level-d)
+	}
+	for _, level := range levels {
+		if level < 1 || level > len(placement.BANDS) {
+			continue
+		}
+		err := query(level).Where("skill = ?", step.Skill).Order("RAND()").Limit(1).Find(&item).Error
+		if err == nil && item.ID == 0 {
+			err = query(level).Order("RAND()").Limit(1).Find(&item).Error
+		}
+		if err != nil || item.ID > 0 {
+			return item, err
+		}
+	}
+	return item, nil
+}
+
+func placementQuestion(item model.PlacementItem, number int) *PlacementQuestion {
+	q := &PlacementQuestion{
+		ID:     item.ID,
+		Number: number,
+		Skill:  item.Skill,
+		Prompt: item.Prompt,
+		Media:  item.Media,
+	}
+	if err := json.Unmarshal([]byte(item.Options), &q.Options); err != nil {
+		log.Error("placement item options error: ", item.ID, err)
+	}
+	return q
+}
+
+var errPlacementAnswered = errors.New("answer already recorded")
+
+// abandonPlacement ends a session without scoring it.
+func abandonPlacement(db *gorm.DB, session *model.PlacementSession) error {
+	return db.Model(&model.PlacementSession{}).
+		Where("id = ? and item_id = ? and status = ?", session.ID, session.ItemID, model.PLACEMENT_STATUS_ACTIVE).
+		Updates(map[string]interface{}{"status": model.PLACEMENT_STATUS_ABANDONED, "item_id": 0, "update_time": time.Now()}).Error
+}
+
+// finishPlacement scores the session and records the level as the
+// learner's in the session's language.
+func finishPlacement(db *gorm.DB, session *model.PlacementSession, answers []placement.Answer) (placement.Result, error) {
+	result := placement.Score(answers)
+	raw, _ := json.Marshal(answers)
+	scored, _ := json.Marshal(result)
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&model.PlacementSession{}).
+			Where("id = ? and item_id = ? and status = ?", session.ID, session.ItemID, model.PLACEMENT_STATUS_ACTIVE).
+			Updates(map[string]interface{}{
+				"status":      model.PLACEMENT_STATUS_FINISHED,
+				"item_id":     0,
+				"answers":     string(raw),
+				"result":      string(scored),
+				"level":       result.Level,
+				"update_time": now,
+			})
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			return errPlacementAnswered
+		}
+		level := tx.Model(&model.LearnerLevel{}).
+			Where("user_id = ? and member_id = ? and language = ?", session.UserID, session.MemberID, session.Language).
+			Updates(map[string]interface{}{"level": result.Level, "session_id": session.ID, "update_time": now})
+		if level.Error != nil || level.RowsAffected > 0 {
+			return level.Error
+		}
+		return tx.Create(&model.LearnerLevel{
+			UserID:     session.UserID,
+			MemberID:   session.MemberID,
+			Language:   session.Language,
+			Level:      result.Level,
+			SessionID:  session.ID,
+			UpdateTime: now,
+			AddTime:    now,
+		}).Error
+	})
+	return result, err
+}
+
+// PlacementStart begins a placement test in a language for the caller or
+// one of their members, abandoning any test the learner left unfinished.
+func PlacementStart(c *gin.Context) {
+	var req PlacementStartRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var errs validation.Errors
+	language, err := validation.Language(req.Language)
+	errs.Check("language", err)
+	if !common.InvalidFields(&res, errs) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	userID, memberID, ok := bookingLearner(db, int64(callerID), req.MemberID, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	step, _ := placement.Next(nil)
+	item, err := nextPlacementItem(db, language, step, nil)
+	if err != nil {
+		log.Error("pick placement item error: ", language, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "start placement failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if item.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "no placement test for this language yet"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	now := time.Now()
+	session := model.PlacementSession{
+		UserID:     uint64(userID),
+		MemberID:   memberID,
+		Language:   language,
+		Status:     model.PLACEMENT_STATUS_ACTIVE,
+		ItemID:     item.ID,
+		Answers:    "[]",
+		UpdateTime: now,
+		AddTime:    now,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.PlacementSession{}).
+			Where("user_id = ? and member_id = ? and status = ?", userID, memberID, model.PLACEMENT_STATUS_ACTIVE).
+			Updates(map[string]interface{}{"status": model.PLACEMENT_STATUS_ABANDONED, "update_time": now}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&session).Error
+	})
+	if err != nil {
+		log.Error("start placement error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "start placement failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = PlacementState{
+		SessionID: session.ID,
+		Question:  placementQuestion(item, 1),
+		MaxItems:  placement.MAX_ITEMS,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// PlacementAnswer records the answer to the current question and returns
+// the next one, or the result once the test is over.
+func PlacementAnswer(c *gin.Context) {
+	var req PlacementAnswerRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	accountID, pinned := bookingAccount(int64(callerID))
+
+	db := system.GetDb()
+	var session model.PlacementSession
+	query := db.Model(&model.PlacementSession{}).Where("id = ? and user_id = ?", req.SessionID, accountID)
+	if pinned.set {
+		query = query.Where("member_id = ?", pinned.memberID)
+	}
+	query.First(&session)
+	if session.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "placement test not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if session.Status != model.PLACEMENT_STATUS_ACTIVE {
+		res.Code = codes.CODE_STATUS_INVALID
+		res.Msg = "placement test is no longer running"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if time.Since(session.AddTime) > PLACEMENT_SESSION_TTL {
+		db.Model(&model.PlacementSession{}).Where("id = ?", session.ID).
+			Updates(map[string]interface{}{"status": model.PLACEMENT_STATUS_ABANDONED, "update_time": time.Now()})
+		res.Code = codes.CODE_ERR_REQ_EXPIRED
+		res.Msg = "placement test expired, please start again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if req.ItemID != session.ItemID {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "that is not the current question"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var item model.PlacementItem
+	db.Model(&model.PlacementItem{}).Where("id = ?", session.ItemID).First(&item)
+	var answers []placement.Answer
+	if err := json.Unmarshal([]byte(session.Answers), &answers); err != nil {
+		log.Error("placement answers error: ", session.ID, err)
+	}
+	answers = append(answers, placement.Answer{
+		ItemID:  item.ID,
+		Skill:   item.Skill,
+		Level:   item.Level,
+		Correct: item.ID > 0 && req.Choice == item.Answer,
+	})
+
+	var next model.PlacementItem
+	step, more := placement.Next(answers)
+	if more {
+		var err error
+		if next, err = nextPlacementItem(db, session.Language, step, answers); err != nil {
+			log.Error("pick placement item error: ", session.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save answer failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+
+	state := PlacementState{SessionID: session.ID, MaxItems: placement.MAX_ITEMS}
+	if more && next.ID == 0 && len(answers) < placement.MIN_ITEMS {
+		// the bank ran out before the test could place anyone
+		if err := abandonPlacement(db, &session); err != nil {
+			log.Error("abandon placement error: ", session.ID, err)
+		}
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "not enough placement items for this language yet"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if next.ID == 0 {
+		// finished, or the bank ran out of items after enough answers
+		result, err := finishPlacement(db, &session, answers)
+		if errors.Is(err, errPlacementAnswered) {
+			res.Code = codes.CODE_ERR_REPEAT
+			res.Msg = err.Error()
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if err != nil {
+			log.Error("finish placement error: ", session.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "finish placement failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		state.Finished = true
+		state.Result = &result
+	} else {
+		raw, _ := json.Marshal(answers)
+		// the item_id condition stops a replayed answer from counting twice
+		update := db.Model(&model.PlacementSession{}).
+			Where("id = ? and item_id = ? and status = ?", session.ID, session.ItemID, model.PLACEMENT_STATUS_ACTIVE).
+			Updates(map[string]interface{}{"item_id": next.ID, "answers": string(raw), "update_time": time.Now()})
+		if update.Error != nil {
+			log.Error("save placement answer error: ", session.ID, update.Error)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save answer failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if update.RowsAffected == 0 {
+			res.Code = codes.CODE_ERR_REPEAT
+			res.Msg = errPlacementAnswered.Error()
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		state.Question = placementQuestion(next, len(answers)+1)
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = state
+	c.JSON(http.StatusOK, res)
+}
+
+// PlacementResult returns the learner's latest finished test, with an
+// optional ?member_id=.
+func PlacementResult(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	accountID, who, ok := learnerQuery(c, db, int64(callerID), &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var session model.PlacementSession
+	err := db.Model(&model.PlacementSession{}).
+		Where("user_id = ? and member_id = ? and status = ?", accountID, who.memberID, model.PLACEMENT_STATUS_FINISHED).
+		Order("id DESC").
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "no placement test taken yet"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err != nil {
+		log.Error("load placement result error: ", accountID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load result failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var result placement.Result
+	if err := json.Unmarshal([]byte(session.Result), &result); err != nil {
+		log.Error("placement result error: ", session.ID, err)
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"session":  session,
+		"result":   result,
+		"taken_at": session.UpdateTime,
+	}
+	c.JSON(http.StatusOK, res)
+}
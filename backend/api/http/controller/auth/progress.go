@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/placement"
+	"github.com/langbridge/backend/progress"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/timezone"
+	"github.com/langbridge/backend/utils"
+	"github.com/langbridge/backend/validation"
+	"gorm.io/gorm"
+)
+
+// GoalRequest creates a goal, or updates it when ID is set. Give a target
+// band, an exam, or both; an exam alone aims at the level it certifies.
+type GoalRequest struct {
+	ID         uint64 `json:"id"`
+	MemberID   uint64 `json:"member_id"` // the member the goal is for, 0 for the caller; ignored on update
+	Language   string `json:"language"`
+	TargetBand string `json:"target_band"` // CEFR band, e.g. "B2"
+	Exam       string `json:"exam"`        // KET, PET, FCE, CAE or CPE
+	TargetDate string `json:"target_date"` // yyyy-MM-dd, optional
+}
+
+type GoalDeleteRequest struct {
+	ID uint64 `json:"id" binding:"required"`
+}
+
+type GoalItem struct {
+	model.LearningGoal
+	TargetBand string                `json:"target_band"`
+	Summary    progress.Summary      `json:"summary"` // the learner in the goal's language
+	Progress   progress.GoalProgress `json:"progress"`
+}
+
+// learnerHistory is everything the learner has done, tagged with the
+// language it was in so it can be split per goal. A level only ever comes
+// from an assessment in the same language.
+type learnerHistory struct {
+	assessments []progress.Assessment
+	assessLangs []string
+	lessons     []progress.Lesson
+	lessonLangs []string
+}
+
+// sameLanguage compares the primary language of two tags, so "en-GB"
+// matches "en"; "" matches everything.
+func sameLanguage(filter, tag string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	primary := func(s string) string {
+		s, _, _ = strings.Cut(s, "-")
+		return s
+	}
+	return strings.EqualFold(primary(filter), primary(tag))
+}
+
+func (h *learnerHistory) in(language string) ([]progress.Assessment, []progress.Lesson) {
+	var assessments []progress.Assessment
+	var lessons []progress.Lesson
+	for i, a := range h.assessments {
+		if sameLanguage(language, h.assessLangs[i]) {
+			assessments = append(assessments, a)
+		}
+	}
+	for i, l := range h.lessons {
+		if sameLanguage(language, h.lessonLangs[i]) {
+			lessons = append(lessons, l)
+		}
+	}
+	return assessments, lessons
+}
+
+// summary is the learner in one language, "" for all of them.
+func (h *learnerHistory) summary(language string, now time.Time) progress.Summary {
+	assessments, lessons := h.in(language)
+	return progress.Summarize(assessments, lessons, now)
+}
+
+func loadHistory(db *gorm.DB, accountID, memberID uint64) (*learnerHistory, error) {
+	h := &learnerHistory{}
+	var sessions []model.PlacementSession
+	err := db.Model(&model.PlacementSession{}).
+		Where("user_id = ? and member_id = ? and status = ?", accountID, memberID, model.PLACEMENT_STATUS_FINISHED).
+		Order("id DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		var result placement.Result
+		if err := json.Unmarshal([]byte(s.Result), &result); err != nil {
+			log.Error("placement result error: ", s.ID, err)
+		}
+		h.assessments = append(h.assessments, progress.Assessment{Level: s.Level, Skills: result.Skills, At: s.UpdateTime})
+		h.assessLangs = append(h.assessLangs, s.Language)
+	}
+
+	var rows []struct {
+		model.CourseBookTrans
+		Language string
+	}
+	store := timezone.Lesson()
+	today, _ := utils.WallClock(time.Now(), store)
+	err = db.Table("course_book_trans").
+		Joins("LEFT JOIN course_info ON course_book_trans.course_id = course_info.id").
+		Select("course_book_trans.*, course_info.language AS language").
+		Where("course_book_trans.user_id = ? and course_book_trans.member_id = ? and course_book_trans.status = ? and course_book_trans.lesson_date <= ?",
+			accountID, memberID, "000", today.Format("2006-01-02")).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		start, end, err := utils.LessonSpan(r.LessonDate, r.StartTime, r.EndTime, store)
+		if err != nil {
+			continue
+		}
+		h.lessons = append(h.lessons, progress.Lesson{Start: start, End: end})
+		h.lessonLangs = append(h.lessonLangs, r.Language)
+	}
+	return h, nil
+}
+
+func goalItem(goal model.LearningGoal, h *learnerHistory, now time.Time) GoalItem {
+	assessments, lessons := h.in(goal.Language)
+	summary := progress.Summarize(assessments, lessons, now)
+	target := progress.Goal{StartLevel: goal.StartLevel, TargetLevel: goal.TargetLevel, Since: goal.AddTime}
+	if len(goal.TargetDate) > 0 {
+		target.TargetDate, _ = time.ParseInLocation(progress.DATE_FORMAT, goal.TargetDate, now.Location())
+	}
+	return GoalItem{
+		LearningGoal: goal,
+		TargetBand:   placement.Band(goal.TargetLevel),
+		Summary:      summary,
+		Progress:     progress.Project(target, summary, lessons, now),
+	}
+}
+
+// Progress reports where a learner stands, overall and per skill, and how
+// far they are from each of their goals. ?member_id= picks a member and
+// ?language= narrows the overall summary.
+func Progress(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	accountID, who, ok := learnerQuery(c, db, int64(callerID), &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	h, err := loadHistory(db, uint64(accountID), who.memberID)
+	if err != nil {
+		log.Error("load progress history error: ", accountID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load progress failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	var goals []model.LearningGoal
+	err = db.Model(&model.LearningGoal{}).
+		Where("user_id = ? and member_id = ? and flag != ?", accountID, who.memberID, -1).
+		Order("id ASC").
+		Find(&goals).Error
+	if err != nil {
+		log.Error("list learning goals error: ", accountID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load progress failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	now := time.Now()
+	items := []GoalItem{}
+	for _, goal := range goals {
+		items = append(items, goalItem(goal, h, now))
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"member_id": who.memberID,
+		"summary":   h.summary(c.Query("language"), now),
+		"goals":     items,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// validateGoal checks the request and fills the goal's target fields.
+func validateGoal(req *GoalRequest, goal *model.LearningGoal, now time.Time, res *common.Response) bool {
+	var errs validation.Errors
+	if lang, err := validation.Language(req.Language); errs.Check("language", err) {
+		goal.Language = lang
+	}
+
+	goal.Exam = ""
+	examLevel := 0
+	if len(strings.TrimSpace(req.Exam)) > 0 {
+		exam, level, ok := progress.ExamLevel(req.Exam)
+		if ok {
+			goal.Exam, examLevel = exam, level
+		} else {
+			errs.Add("exam", validation.CODE_INVALID, "exam must be one of KET, PET, FCE, CAE, CPE")
+		}
+	}
+	switch band := strings.ToUpper(strings.TrimSpace(req.TargetBand)); {
+	case len(band) > 0:
+		level, ok := placement.Level(band)
+		if !ok {
+			errs.Add("target_band", validation.CODE_INVALID, "target_band must be one of "+strings.Join(placement.BANDS, ", "))
+		} else if level < examLevel {
+			errs.Add("target_band", validation.CODE_INVALID, "target_band is below the level the exam certifies")
+		} else {
+			goal.TargetLevel = level
+		}
+	case examLevel > 0:
+		goal.TargetLevel = examLevel
+	case len(strings.TrimSpace(req.Exam)) == 0:
+		errs.Add("target_band", validation.CODE_REQUIRED, "target_band or exam is required")
+	}
+
+	goal.TargetDate = ""
+	if date := strings.TrimSpace(req.TargetDate); len(date) > 0 {
+		d, err := time.ParseInLocation(progress.DATE_FORMAT, date, now.Location())
+		if err != nil {
+			errs.Add("target_date", validation.CODE_INVALID, "not a valid date, expected yyyy-MM-dd")
+		} else if !d.After(now) {
+			errs.Add("target_date", validation.CODE_INVALID, "target_date must be in the future")
+		} else {
+			goal.TargetDate = d.Format(progress.DATE_FORMAT)
+		}
+	}
+	return common.InvalidFields(res, errs)
+}
+
+func GoalSave(c *gin.Context) {
+	var req GoalRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var goal model.LearningGoal
+	if req.ID > 0 {
+		accountID, pinned := bookingAccount(int64(callerID))
+		pinned.where(db.Model(&model.LearningGoal{}), "member_id").
+			Where("id = ? and user_id = ? and flag != ?", req.ID, accountID, -1).
+			First(&goal)
+		if goal.ID == 0 {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "goal not found"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	} else {
+		accountID, memberID, ok := bookingLearner(db, int64(callerID), req.MemberID, &res)
+		if !ok {
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		goal.UserID, goal.MemberID = uint64(accountID), memberID
+	}
+
+	now := time.Now()
+	if !validateGoal(&req, &goal, now, &res) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var err error
+	if goal.ID > 0 {
+		goal.UpdateTime = now
+		err = db.Model(&model.LearningGoal{}).Where("id = ?", goal.ID).Updates(map[string]interface{}{
+			"language":     goal.Language,
+			"target_level": goal.TargetLevel,
+			"exam":         goal.Exam,
+			"target_date":  goal.TargetDate,
+			"update_time":  now,
+		}).Error
+	} else {
+		h, herr := loadHistory(db, goal.UserID, goal.MemberID)
+		if herr != nil {
+			err = herr
+		} else {
+			goal.StartLevel = h.summary(goal.Language, now).Level
+			goal.AddTime, goal.UpdateTime = now, now
+			err = db.Create(&goal).Error
+		}
+	}
+	if err != nil {
+		log.Error("save learning goal error: ", goal.UserID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "save goal failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = goal
+	c.JSON(http.StatusOK, res)
+}
+
+func GoalDelete(c *gin.Context) {
+	var req GoalDeleteRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	callerID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	accountID, pinned := bookingAccount(int64(callerID))
+	update := pinned.where(system.GetDb().Model(&model.LearningGoal{}), "member_id").
+		Where("id = ? and user_id = ? and flag != ?", req.ID, accountID, -1).
+		Updates(map[string]interface{}{"flag": -1, "update_time": time.Now()})
+	if update.Error != nil {
+		log.Error("delete learning goal error: ", req.ID, update.Error)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "delete goal failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if update.RowsAffected == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "goal not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+)
+
+type LogoutRequest struct {
+	All bool `json:"all"`
+}
+
+type RevokeSessionRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
+}
+
+type SessionItem struct {
+	session.Session
+	Current bool `json:"current"`
+}
+
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	// the body is optional, an empty one logs out the current device only
+	_ = c.ShouldBindJSON(&req)
+
+	userID, sid, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var err error
+	if req.All {
+		err = session.RevokeAll(userID)
+	} else if len(sid) > 0 {
+		err = session.Revoke(userID, sid)
+	}
+	if err != nil && err != session.ErrSessionNotFound {
+		log.Error("logout error: ", userID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "logout failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+func SessionList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, sid, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	sessions, err := session.List(userID)
+	if err != nil {
+		log.Error("list sessions error: ", userID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "list sessions failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	result := make([]SessionItem, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, SessionItem{Session: s, Current: s.ID == sid})
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = result
+	c.JSON(http.StatusOK, res)
+}
+
+func SessionRevoke(c *gin.Context) {
+	var req RevokeSessionRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	err := session.Revoke(userID, req.SessionID)
+	if err == session.ErrSessionNotFound {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "session not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err != nil {
+		log.Error("revoke session error: ", userID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "revoke session failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+// currentSession returns the caller's user id and, for session bound tokens,
+// the session id.
+func currentSession(c *gin.Context) (uint64, string, bool) {
+	currentUser, exist := c.Get("user_id")
+	if !exist {
+		return 0, "", false
+	}
+	currentUserStr, _ := currentUser.(string)
+	userID, err := strconv.ParseUint(currentUserStr, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	var sid string
+	if v, ok := c.Get("claims"); ok {
+		if claims, ok := v.(*security.Claims); ok {
+			sid = claims.SessionID
+		}
+	}
+	return userID, sid, true
+}
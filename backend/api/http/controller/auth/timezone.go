@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/timezone"
+	"github.com/langbridge/backend/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	ZONE_LOCAL  = "local" // the viewer's own zone, the default
+	ZONE_UTC    = "utc"
+	ZONE_LESSON = "lesson" // the zone lessons and teacher slot templates are stored in
+)
+
+// requestZone is the zone the request's dates and times are written in and
+// the response's are rendered in: "local" (or nothing) for the viewer's
+// zone, "utc" for UTC, "lesson" for the lesson zone.
+func requestZone(db *gorm.DB, userID int64, zone string, res *common.Response) (*time.Location, bool) {
+	switch strings.ToLower(zone) {
+	case "", ZONE_LOCAL:
+		return timezone.ForUser(db, uint64(userID)), true
+	case ZONE_UTC:
+		return time.UTC, true
+	case ZONE_LESSON:
+		return timezone.Lesson(), true
+	}
+	res.Code = codes.CODE_ERR_BAD_PARAMS
+	res.Msg = "zone must be local, utc or lesson"
+	return nil, false
+}
+
+// renderLesson rewrites a stored lesson into loc: the date and clocks are
+// the ones a viewer in loc sees, StartAt and EndAt carry the offset.
+func renderLesson(b *model.CourseBookWithJoin, loc *time.Location) {
+	start, end, err := utils.LessonSpan(b.LessonDate, b.StartTime, b.EndTime, timezone.Lesson())
+	if err != nil {
+		return
+	}
+	b.StartAt, b.EndAt, b.Timezone = start.In(loc), end.In(loc), loc.String()
+	b.LessonDate, b.StartTime = utils.WallClock(start, loc)
+	_, b.EndTime = utils.WallClock(end, loc)
+}
+
+// dbDate carries a calendar date to a DATE column. The driver converts
+// times to its own zone on write, so the date is built there to keep the
+// day from shifting.
+func dbDate(date time.Time) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
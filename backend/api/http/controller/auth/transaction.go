@@ -13,6 +13,7 @@ import (
 	"github.com/langbridge/backend/codes"
 	"github.com/langbridge/backend/log"
 	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/notify"
 	"github.com/langbridge/backend/system"
 	"github.com/langbridge/backend/thirdpart"
 )
@@ -193,10 +194,16 @@ func Notify(c *gin.Context) {
 		log.Errorf("[NotifyTrans] session_userid:%d, tx_userid:%d", userID, userTrans.UserID)
 	}
 
+	// the receipt goes out once, not on every repeated report, and only to
+	// the user the transaction belongs to when they report it themselves
+	first := userTrans.Status == "00" && userTrans.UserID == userID
 	userTrans.TxHash = req.TxHash
 	userTrans.UpdateTime = time.Now()
 	userTrans.Status = "10"
 	db.Save(&userTrans)
+	if first {
+		go notify.PaymentReceived(userTrans)
+	}
 
 	redis := system.GetRedis()
 	if redis != nil {
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/twofactor"
+)
+
+type TwoFactorEnableRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP or backup code
+}
+
+// TwoFactorEnroll starts TOTP enrollment. The client renders uri as a QR
+// code; nothing is enforced until TwoFactorEnable confirms a first code.
+func TwoFactorEnroll(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_TX
+		res.Msg = "please login"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	secret, err := twofactor.Begin(userID)
+	if err != nil {
+		if errors.Is(err, twofactor.ErrAlreadyEnabled) {
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "two-factor authentication is already enabled"
+		} else {
+			log.Error("2fa enroll error: ", userID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "two-factor enrollment failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"secret": secret,
+		"uri":    security.TOTPProvisioningURI(twofactor.ISSUER, userInfo.Email, secret),
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func TwoFactorEnable(c *gin.Context) {
+	var req TwoFactorEnableRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	backupCodes, err := twofactor.Enable(userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, twofactor.ErrNotEnrolled):
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "start two-factor enrollment first"
+		case errors.Is(err, twofactor.ErrAlreadyEnabled):
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "two-factor authentication is already enabled"
+		case errors.Is(err, twofactor.ErrCodeInvalid):
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "verification code is incorrect"
+		default:
+			log.Error("2fa enable error: ", userID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "enable two-factor failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{"backup_codes": backupCodes}
+	c.JSON(http.StatusOK, res)
+}
+
+// TwoFactorDisable needs both the password and a second-factor code, so a
+// stolen session alone cannot turn 2FA off.
+func TwoFactorDisable(c *gin.Context) {
+	var req TwoFactorDisableRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if match, _ := security.VerifyPassword(req.Password, userInfo.Password); userInfo.ID == 0 || !match {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "password or verification code is incorrect"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err := twofactor.Verify(userID, req.Code); err != nil {
+		if errors.Is(err, twofactor.ErrNotEnrolled) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "two-factor authentication is not enabled"
+		} else {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "password or verification code is incorrect"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if err := twofactor.Disable(userID); err != nil {
+		log.Error("2fa disable error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "disable two-factor failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
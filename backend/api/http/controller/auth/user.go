@@ -7,9 +7,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
 	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
 	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/photo"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
 	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
 )
 
 type UpdateProfileRequest struct {
@@ -18,6 +24,13 @@ type UpdateProfileRequest struct {
 	LivingCountryID uint64 `json:"living_country_id"`
 	Phone           string `json:"phone"`
 	NativeLanguage  string `json:"native_language"`
+	Timezone        string `json:"timezone"` // IANA zone, e.g. Asia/Shanghai
+	Locale          string `json:"locale"`   // BCP 47 tag, e.g. zh-CN
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
 }
 
 func RetrieveProfile(c *gin.Context) {
@@ -63,6 +76,8 @@ func RetrieveProfile(c *gin.Context) {
 		Avatar          string `json:"avatar"`
 		Phone           string `json:"phone"`
 		NickName        string `json:"nick_name"`
+		Timezone        string `json:"timezone"`
+		Locale          string `json:"locale"`
 	}{
 		UserNo:          userInfo.UserNo,
 		NickName:        userProfile.NickName,
@@ -72,6 +87,8 @@ func RetrieveProfile(c *gin.Context) {
 		NativeLanguage:  userProfile.NativeLanguage,
 		Avatar:          userProfile.Avatar,
 		Phone:           userProfile.ContactPhone,
+		Timezone:        userProfile.Timezone,
+		Locale:          userProfile.Locale,
 	}
 	c.JSON(http.StatusOK, res)
 }
@@ -119,29 +136,187 @@ func UpdateProfile(c *gin.Context) {
 	db.Model(&model.UserProfile{}).Where("user_id = ?", userInfo.ID).First(&userProfile)
 
 	if userProfile.ID > 0 {
+		before := userProfile
+		var errs validation.Errors
+		if req.LivingCountryID > 0 {
+			var countryObj model.DictCountry
+			db.Model(&model.DictCountry{}).Where("id = ?", req.LivingCountryID).First(&countryObj)
+			if countryObj.ID > 0 {
+				userProfile.LivingCountryCode = countryObj.PhoneCode
+				userProfile.LivingCountryID = countryObj.ID
+				userProfile.LivingCountryName = countryObj.Name
+			} else {
+				errs.Add("living_country_id", validation.CODE_INVALID, "unknown country")
+			}
+		}
 		if len(req.Avatar) > 0 {
-			userProfile.Avatar = req.Avatar
+			if avatar, err := validation.URL(req.Avatar); errs.Check("avatar", err) &&
+				errs.Check("avatar", photo.CheckURL(photo.AvatarDir(userInfo.UserNo), avatar)) {
+				userProfile.Avatar = avatar
+			}
 		}
 		if len(req.NativeLanguage) > 0 {
-			userProfile.NativeLanguage = req.NativeLanguage
+			if lang, err := validation.Language(req.NativeLanguage); errs.Check("native_language", err) {
+				userProfile.NativeLanguage = lang
+			}
 		}
 		if len(req.Phone) > 0 {
-			userProfile.ContactPhone = req.Phone
+			// national numbers are taken to be in the (possibly new) living country
+			if phone, err := validation.Phone(req.Phone, userProfile.LivingCountryCode); errs.Check("phone", err) {
+				userProfile.ContactPhone = phone
+			}
+		}
+		if len(req.Timezone) > 0 {
+			if tz, err := validation.Timezone(req.Timezone); errs.Check("timezone", err) {
+				userProfile.Timezone = tz
+			}
+		}
+		if len(req.Locale) > 0 {
+			if locale, err := validation.Language(req.Locale); errs.Check("locale", err) {
+				userProfile.Locale = locale
+			}
 		}
 		if len(req.NickName) > 0 {
+			errs.MaxLen("nick_name", req.NickName, 64)
 			userProfile.NickName = req.NickName
 		}
-		if req.LivingCountryID > 0 {
-			var countryObj model.DictCountry
-			db.Model(&model.DictCountry{}).Where("id = ?", req.LivingCountryID).First(&countryObj)
-			if countryObj.ID > 0 {
-				userProfile.LivingCountryCode = countryObj.PhoneCode
-				userProfile.LivingCountryID = countryObj.ID
-				userProfile.LivingCountryName = countryObj.Name
-			}
+		if !common.InvalidFields(&res, errs) {
+			c.JSON(http.StatusOK, res)
+			return
 		}
-		db.Model(&model.UserProfile{}).Where("id = ?", userProfile.ID).Updates(&userProfile)
+		if err := db.Model(&model.UserProfile{}).Where("id = ?", userProfile.ID).Updates(&userProfile).Error; err == nil {
+			audit.Record(c, audit.ACTION_PROFILE_UPDATE, userInfo.ID, before, userProfile)
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// UploadAvatar takes a multipart "file", stores it in the avatar sizes and
+// makes it the profile avatar. The previous uploaded avatar is removed.
+func UploadAvatar(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var userInfo model.UserInfo
+	db.Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	var userProfile model.UserProfile
+	db.Model(&model.UserProfile{}).Where("user_id = ?", userID).First(&userProfile)
+	if userInfo.ID == 0 || userProfile.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user profile is not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	uploaded, err := photo.Upload(c, photo.AvatarDir(userInfo.UserNo))
+	if err != nil {
+		common.UploadFailed(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	before := userProfile
+	userProfile.Avatar = uploaded.URL
+	userProfile.UpdateTime = time.Now()
+	err = db.Model(&model.UserProfile{}).Where("id = ?", userProfile.ID).
+		Updates(map[string]interface{}{"avatar": userProfile.Avatar, "update_time": userProfile.UpdateTime}).Error
+	if err != nil {
+		photo.Discard(c.Request.Context(), photo.AvatarDir(userInfo.UserNo), uploaded.URL)
+		log.Error("save avatar error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "save avatar failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_PROFILE_UPDATE, userID, before, userProfile)
+	photo.Discard(c.Request.Context(), photo.AvatarDir(userInfo.UserNo), before.Avatar)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = uploaded
+	c.JSON(http.StatusOK, res)
+}
+
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	currentUser, exist := c.Get("user_id")
+
+	if !exist {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	currentUserStr, _ := currentUser.(string)
+	userID, err := strconv.ParseInt(currentUserStr, 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var userInfo model.UserInfo
+	db.Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_TX
+		res.Msg = "please login"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if match, _ := security.VerifyPassword(req.OldPassword, userInfo.Password); !match {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "old password is incorrect"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	passwordHash, err := security.HashPassword(req.NewPassword)
+	if err != nil {
+		log.Error("hash password error: ", err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "change password failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	err = db.Model(&model.UserInfo{}).Where("id = ?", userInfo.ID).
+		Updates(map[string]interface{}{"password": passwordHash, "update_time": time.Now()}).Error
+	if err != nil {
+		log.Error("change password update error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "change password failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if err := session.RevokeAll(userInfo.ID); err != nil {
+		log.Error("revoke tokens after password change error: ", userInfo.ID, err)
 	}
 
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
 	c.JSON(http.StatusOK, res)
 }
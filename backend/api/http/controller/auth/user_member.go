@@ -1,15 +1,23 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/account"
 	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
 	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/family"
+	"github.com/langbridge/backend/log"
 	"github.com/langbridge/backend/model"
 	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
 )
 
 type MemberFormRequest struct {
@@ -22,6 +30,19 @@ type MemberFormRequest struct {
 	Birthday    string `json:"birthday"`
 	Personality string `json:"personality"`
 	Character   string `json:"character"`
+	Invite      bool   `json:"invite"`  // email the member an invite to their own login
+	Consent     bool   `json:"consent"` // guardian consent, needed for members under the consent age
+}
+
+type MemberInviteRequest struct {
+	MemberID uint64 `json:"member_id" binding:"required"`
+	Consent  bool   `json:"consent"`
+}
+
+type MemberBookingRequest struct {
+	MemberID uint64 `json:"member_id" binding:"required"`
+	Allow    bool   `json:"allow"`
+	Consent  bool   `json:"consent"`
 }
 
 func FetchMemberList(c *gin.Context) {
@@ -83,6 +104,18 @@ func FetchMemberAdd(c *gin.Context) {
 		return
 	}
 
+	if !validateMember(&req, &res) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if req.Invite && !req.Consent && family.ConsentRequired(model.UserMember{Birthday: req.Birthday}) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = consentMessage()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	db := system.GetDb()
 	var member model.UserMember
 
@@ -91,6 +124,7 @@ func FetchMemberAdd(c *gin.Context) {
 	}
 
 	if member.ID > 0 {
+		before := member
 		member.Name = req.Name
 		member.Birthday = req.Birthday
 		member.Character = req.Character
@@ -101,7 +135,18 @@ func FetchMemberAdd(c *gin.Context) {
 		member.RelType = req.RelType
 		member.UpdateTime = time.Now()
 
-		db.Model(&model.UserMember{}).Where("id = ?", member.ID).Updates(&member)
+		if err := db.Model(&model.UserMember{}).Where("id = ?", member.ID).Updates(&member).Error; err != nil {
+			log.Error("update member error: ", member.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save member failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if before.Email != member.Email && member.LoginUserID == 0 {
+			// the pending invite went to the old address
+			family.CancelInvite(member.ID)
+		}
+		audit.Record(c, audit.ACTION_MEMBER_UPDATE, uint64(userID), before, member)
 	} else {
 		member.Name = req.Name
 		member.Birthday = req.Birthday
@@ -115,13 +160,49 @@ func FetchMemberAdd(c *gin.Context) {
 		member.AddTime = time.Now()
 		member.UserID = uint64(userID)
 		member.Flag = 0
-		db.Model(&model.UserMember{}).Save(&member)
+		if err := db.Model(&model.UserMember{}).Save(&member).Error; err != nil {
+			log.Error("add member error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "save member failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		audit.Record(c, audit.ACTION_MEMBER_ADD, uint64(userID), nil, member)
+	}
+
+	if req.Invite && member.ID > 0 {
+		inviteMember(c, &res, uint64(userID), member, req.Consent)
 	}
 
 	res.Data = member
 	c.JSON(http.StatusOK, res)
 }
 
+// validateMember normalises the form in place. It fills res with the field
+// errors and returns false if any field is refused.
+func validateMember(req *MemberFormRequest, res *common.Response) bool {
+	var errs validation.Errors
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) == 0 {
+		errs.Add("name", validation.CODE_REQUIRED, "name is required")
+	}
+	errs.MaxLen("name", req.Name, 64)
+	if len(req.Email) > 0 {
+		if email, err := validation.Email(req.Email); errs.Check("email", err) {
+			req.Email = email
+		}
+	}
+	if len(req.Birthday) > 0 {
+		if birthday, err := validation.Date(req.Birthday, time.Now()); errs.Check("birthday", err) {
+			req.Birthday = birthday
+		}
+	}
+	errs.MaxLen("rel_desc", req.RelDesc, 255)
+	errs.MaxLen("personality", req.Personality, 1000)
+	errs.MaxLen("character", req.Character, 1000)
+	return common.InvalidFields(res, errs)
+}
+
 func FetchMemberDelete(c *gin.Context) {
 	res := common.Response{}
 	res.Timestamp = time.Now().Unix()
@@ -163,10 +244,164 @@ func FetchMemberDelete(c *gin.Context) {
 		return
 	}
 
+	before := member
 	member.Flag = -1
 	member.UpdateTime = time.Now()
 	db.Model(&model.UserMember{}).Where("id = ?", member.ID).Update("flag", -1)
+	if member.LoginUserID > 0 {
+		// the member's own login would otherwise live on as a standalone
+		// account holding the member's name and email
+		if err := account.Purge(member.LoginUserID); err != nil {
+			log.Error("purge member login error: ", member.ID, err)
+		}
+	} else {
+		family.CancelInvite(member.ID)
+	}
+	audit.Record(c, audit.ACTION_MEMBER_DELETE, uint64(userID), before, member)
+
+	res.Data = member
+	c.JSON(http.StatusOK, res)
+}
+
+func consentMessage() string {
+	return fmt.Sprintf("guardian consent is required for members under %d", family.ConsentAge())
+}
+
+// inviteMember records consent where needed and emails the invite. It fills
+// res on failure.
+func inviteMember(c *gin.Context, res *common.Response, guardianID uint64, member model.UserMember, consent bool) bool {
+	if family.ConsentRequired(member) {
+		if !consent {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = consentMessage()
+			return false
+		}
+		if err := family.RecordConsent(guardianID, member.ID, model.CONSENT_LOGIN, true, c.ClientIP()); err != nil {
+			log.Error("record guardian consent error: ", member.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "record consent failed"
+			return false
+		}
+	}
+
+	var guardian model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", guardianID).First(&guardian)
+	if err := family.Invite(guardian, member); err != nil {
+		switch {
+		case errors.Is(err, family.ErrNoEmail):
+			res.Code = codes.CODE_ERR_PARA_EMPTY
+			res.Msg = "member email is required for an invite"
+		case errors.Is(err, family.ErrAlreadyInvited):
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "member already has a login"
+		default:
+			log.Error("send member invite error: ", member.ID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "send invite failed"
+		}
+		return false
+	}
+	audit.Record(c, audit.ACTION_MEMBER_INVITE, guardianID, nil, gin.H{"member_id": member.ID, "email": member.Email})
+	return true
+}
+
+// FetchMemberInvite emails an existing member an invite to their own login,
+// or sends a fresh link.
+func FetchMemberInvite(c *gin.Context) {
+	var req MemberInviteRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var member model.UserMember
+	system.GetDb().Model(&model.UserMember{}).Where("user_id = ? and flag != ? and id = ?", userID, -1, req.MemberID).First(&member)
+	if member.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "member not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if !inviteMember(c, &res, userID, member, req.Consent) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+// FetchMemberBooking lets the guardian grant or take back a member's right
+// to book lessons from their own login.
+func FetchMemberBooking(c *gin.Context) {
+	var req MemberBookingRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var member model.UserMember
+	system.GetDb().Model(&model.UserMember{}).Where("user_id = ? and flag != ? and id = ?", userID, -1, req.MemberID).First(&member)
+	if member.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "member not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if family.ConsentRequired(member) {
+		if req.Allow && !req.Consent {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = consentMessage()
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if err := family.RecordConsent(userID, member.ID, model.CONSENT_BOOKING, req.Allow, c.ClientIP()); err != nil {
+			log.Error("record guardian consent error: ", member.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "record consent failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+
+	if err := family.SetBooking(member, req.Allow); err != nil {
+		log.Error("set member booking error: ", member.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "update booking permission failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	before := member
+	member.CanBook = req.Allow
+	audit.Record(c, audit.ACTION_MEMBER_BOOKING, userID, before, member)
 
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
 	res.Data = member
 	c.JSON(http.StatusOK, res)
 }
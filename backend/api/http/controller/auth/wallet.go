@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/walletauth"
+)
+
+type WalletLinkRequest struct {
+	ID   uint64 `json:"id" binding:"required,min=1"` // auth message from /preauth/get_msg
+	Sign string `json:"sign" binding:"required"`
+}
+
+type WalletUnlinkRequest struct {
+	Wallet string `json:"wallet" binding:"required"`
+}
+
+// WalletLink attaches the wallet that signed the challenge to the current
+// account, after which it can be used with /login/wallet.
+func WalletLink(c *gin.Context) {
+	var req WalletLinkRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	wallet, err := walletauth.Verify(req.ID, req.Sign)
+	if err != nil {
+		res.Code, res.Msg = walletauth.ErrorCode(err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userWallet, err := walletauth.Link(userID, wallet)
+	if err != nil {
+		res.Code, res.Msg = walletauth.ErrorCode(err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = userWallet
+	c.JSON(http.StatusOK, res)
+}
+
+func WalletUnlink(c *gin.Context) {
+	var req WalletUnlinkRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if err := walletauth.Unlink(userID, req.Wallet); err != nil {
+		res.Code, res.Msg = walletauth.ErrorCode(err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+func WalletList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var wallets []model.UserWallet
+	err := system.GetDb().Model(&model.UserWallet{}).Where("user_id = ?", userID).Order("id asc").Find(&wallets).Error
+	if err != nil {
+		log.Error("list wallets error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load wallets failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = wallets
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,290 @@
+package home
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/mailer"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+	"github.com/langbridge/backend/system"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,min=5"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,min=5"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+const resetTokenTTL = 30 * time.Minute
+
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, err := security.ParseActionToken(security.ACTION_VERIFY_EMAIL, req.Token)
+	if err != nil {
+		if errors.Is(err, security.ErrActionTokenExpired) {
+			res.Code = codes.CODE_ERR_REQ_EXPIRED
+			res.Msg = "verification link expired, please request a new one"
+		} else {
+			res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+			res.Msg = "verification link invalid"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+
+	var userInfo model.UserInfo
+	db.Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user information is not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if userInfo.Status == model.USER_STATUS_UNVERIFIED {
+		err = db.Model(&model.UserInfo{}).Where("id = ? and status = ?", userInfo.ID, model.USER_STATUS_UNVERIFIED).
+			Updates(map[string]interface{}{"status": model.USER_STATUS_ACTIVE, "update_time": time.Now()}).Error
+		if err != nil {
+			log.Error("verify email update status error: ", userInfo.ID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "verify email failed"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = struct {
+		UserNo string `json:"user_no"`
+	}{
+		UserNo: userInfo.UserNo,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// the response is the same whether or not the address is registered,
+	// verified or throttled, and goes out before any of that is looked up
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+
+	email := strings.TrimSpace(req.Email)
+	interval := time.Duration(config.GetConfig().Auth.ResendInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if ok, err := system.Throttle("lb:mail:verify:"+tokenDigest(strings.ToLower(email)), interval); err != nil || !ok {
+		if err != nil {
+			log.Error("verify mail throttle error: ", err)
+		}
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("email = ?", email).First(&userInfo)
+	if userInfo.ID == 0 || userInfo.Status != model.USER_STATUS_UNVERIFIED {
+		return
+	}
+	if err := sendVerificationMail(userInfo); err != nil {
+		log.Error("resend verification mail error: ", userInfo.ID, err)
+	}
+}
+
+func sendVerificationMail(userInfo model.UserInfo) error {
+	conf := config.GetConfig().Auth
+	ttl := time.Duration(conf.VerifyTokenTTL) * time.Minute
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	token, err := security.IssueActionToken(security.ACTION_VERIFY_EMAIL, userInfo.ID, ttl)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(conf.WebBase, "/"), url.QueryEscape(token))
+
+	return mailer.Send(mailer.Message{
+		To:      userInfo.Email,
+		Subject: "Verify your LangBridge email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			userInfo.Name, link, ttl),
+	})
+}
+
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+
+	var userInfo model.UserInfo
+	db.Model(&model.UserInfo{}).Where("email = ?", strings.TrimSpace(req.Email)).First(&userInfo)
+
+	// the response is the same whether or not the address is registered
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if userInfo.ID == 0 {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	interval := time.Duration(config.GetConfig().Auth.ResendInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if ok, err := system.Throttle(fmt.Sprintf("lb:mail:reset:%d", userInfo.ID), interval); err != nil || !ok {
+		if err != nil {
+			log.Error("reset mail throttle error: ", err)
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	token, err := security.IssueActionToken(security.ACTION_RESET_PASSWORD, userInfo.ID, resetTokenTTL)
+	if err == nil {
+		// only the most recently issued reset token is accepted, and only once
+		err = system.GetRedis().Set(c, resetTokenKey(userInfo.ID), tokenDigest(token), resetTokenTTL).Err()
+	}
+	if err != nil {
+		log.Error("issue reset token error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "password reset failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(config.GetConfig().Auth.WebBase, "/"), url.QueryEscape(token))
+	err = mailer.Send(mailer.Message{
+		To:      userInfo.Email,
+		Subject: "Reset your LangBridge password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your LangBridge account. If it was you, open the link below:\n\n%s\n\nThe link expires in %s and can be used once. If you did not ask for this, you can ignore this email.\n",
+			userInfo.Name, link, resetTokenTTL),
+	})
+	if err != nil {
+		log.Error("send reset mail error: ", userInfo.ID, err)
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, err := security.ParseActionToken(security.ACTION_RESET_PASSWORD, req.Token)
+	if err == nil {
+		var stored string
+		stored, err = system.GetRedis().GetDel(c, resetTokenKey(userID)).Result()
+		if err == nil && stored != tokenDigest(req.Token) {
+			err = security.ErrActionTokenInvalid
+		}
+	}
+	if err != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "reset link invalid or expired"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	passwordHash, err := security.HashPassword(req.Password)
+	if err != nil {
+		log.Error("hash password error: ", err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "reset password failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	err = db.Model(&model.UserInfo{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"password": passwordHash, "update_time": time.Now()}).Error
+	if err != nil {
+		log.Error("reset password update error: ", userID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "reset password failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if err := session.RevokeAll(userID); err != nil {
+		log.Error("revoke tokens after password reset error: ", userID, err)
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	c.JSON(http.StatusOK, res)
+}
+
+func resetTokenKey(userID uint64) string {
+	return fmt.Sprintf("lb:pwd:reset:%d", userID)
+}
+
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,20 +1,26 @@
 package home
 
 import (
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
 	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/config"
 	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/loginguard"
 	"github.com/langbridge/backend/model"
 	"github.com/langbridge/backend/security"
 	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/utils"
+	"github.com/langbridge/backend/validation"
 	"gorm.io/gorm"
 )
 
@@ -35,8 +41,9 @@ type RegisterRequest struct {
 }
 
 type LoginRequest struct {
-	LoginName string `json:"login_name"`
-	Password  string `json:"password"`
+	LoginName string `json:"login_name" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+	Device    string `json:"device"`
 }
 
 func Welcome(c *gin.Context) {
@@ -61,6 +68,21 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	var errs validation.Errors
+	if email, err := validation.Email(req.Email); errs.Check("email", err) {
+		req.Email = email
+	}
+	errs.MaxLen("name", req.Name, 64)
+	if len(req.Language) > 0 {
+		if lang, err := validation.Language(req.Language); errs.Check("language", err) {
+			req.Language = lang
+		}
+	}
+	if !common.InvalidFields(&res, errs) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	db := system.GetDb()
 
 	var userInfo model.UserInfo
@@ -93,21 +115,21 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	passwordHash := fmt.Sprintf("%x", sha256.Sum256([]byte(req.Password)))
+	if len(req.Language) > 0 {
+		// the language picked at sign-up wins over the country default
+		countryObj.LanguageCode = req.Language
+	}
 
-	userInfo = model.UserInfo{
-		Email:      req.Email,
-		Password:   passwordHash,
-		Name:       req.Name,
-		CountryID:  countryObj.ID,
-		Language:   countryObj.LanguageCode,
-		AddTime:    time.Now(),
-		UpdateTime: time.Now(),
-		LoginId:    "",
-		UserNo:     system.GenerateUserNoNumberOnly(),
-		Status:     "00", // waiting for email verification
+	passwordHash, err := security.HashPassword(req.Password)
+	if err != nil {
+		log.Error("hash password error: ", err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "create password failed"
+		c.JSON(http.StatusOK, res)
+		return
 	}
-	err = db.Save(&userInfo).Error
+
+	userInfo, err = createUser(db, req.Email, req.Name, passwordHash, countryObj, model.USER_STATUS_UNVERIFIED, security.ROLE_STUDENT)
 	if err != nil {
 		log.Error("create user info error: ", err)
 		res.Code = codes.CODE_ERR_DB_ERROR
@@ -115,16 +137,10 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	// create user profile
-	userProfile := model.UserProfile{
-		UserID:            userInfo.ID,
-		LivingCountryID:   countryObj.ID,
-		LivingCountryName: countryObj.Name,
-		LivingCountryCode: countryObj.PhoneCode,
-		UpdateTime:        time.Now(),
+
+	if err = sendVerificationMail(userInfo); err != nil {
+		log.Error("send verification mail error: ", userInfo.ID, err)
 	}
-	err = db.Save(&userProfile).Error
-	log.Error("save profile error", err)
 
 	res.Code = codes.CODE_SUCCESS
 	res.Msg = "success"
@@ -136,6 +152,88 @@ func Register(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// dummyPasswordHash is verified against when the login name matches no
+// account, so that case is not faster than a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := security.HashPassword("langbridge-no-such-account")
+	return hash
+})
+
+// createUser inserts a new account with its profile and first role. Every
+// sign-up path, password, social or member invite, goes through here.
+func createUser(db *gorm.DB, email, name, passwordHash string, country model.DictCountry, status, role string) (model.UserInfo, error) {
+	userInfo := model.UserInfo{
+		Email:      email,
+		Password:   passwordHash,
+		Name:       name,
+		CountryID:  country.ID,
+		Language:   countryLanguage(country),
+		AddTime:    time.Now(),
+		UpdateTime: time.Now(),
+		LoginId:    "",
+		UserNo:     system.GenerateUserNoNumberOnly(),
+		Status:     status,
+	}
+	// the role goes in with the user: without one the login has no
+	// permissions at all
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&userInfo).Error; err != nil {
+			return err
+		}
+		return tx.Save(&model.UserRole{UserID: userInfo.ID, Role: role, AddTime: time.Now()}).Error
+	})
+	if err != nil {
+		return userInfo, err
+	}
+	// create user profile
+	userProfile := model.UserProfile{
+		UserID:            userInfo.ID,
+		LivingCountryID:   country.ID,
+		LivingCountryName: country.Name,
+		LivingCountryCode: country.PhoneCode,
+		Locale:            countryLocale(userInfo.Language, country),
+		UpdateTime:        time.Now(),
+	}
+	if _, ok := utils.LoadZone(country.Timezone); ok {
+		userProfile.Timezone = country.Timezone
+	}
+	err = db.Save(&userProfile).Error
+	if err != nil {
+		log.Error("save profile error", err)
+	}
+	return userInfo, nil
+}
+
+// countryLanguage is the country's language as a normalised tag. The dict
+// may list several ("en,fr"); the first one is used, and none if it is not a
+// valid code.
+func countryLanguage(country model.DictCountry) string {
+	first, _, _ := strings.Cut(country.LanguageCode, ",")
+	lang, err := validation.Language(first)
+	if err != nil {
+		if len(country.LanguageCode) > 0 {
+			log.Error("invalid country language code: ", country.ID, country.LanguageCode)
+		}
+		return ""
+	}
+	return lang
+}
+
+// countryLocale pairs the account language with the country as region,
+// "en" and GB giving "en-GB". A language that already names a region is
+// kept as it is.
+func countryLocale(language string, country model.DictCountry) string {
+	if len(language) == 0 {
+		return ""
+	}
+	if !strings.Contains(language, "-") {
+		if locale, err := validation.Language(language + "-" + country.Iso2); err == nil {
+			return locale
+		}
+	}
+	return language
+}
+
 func Login(c *gin.Context) {
 	var req LoginRequest
 	res := common.Response{}
@@ -148,6 +246,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	account := loginguard.Account(req.LoginName)
+	if wait := loginguard.Locked(account, c.ClientIP()); wait > 0 {
+		audit.Record(c, audit.ACTION_LOGIN_FAILED, 0, nil, gin.H{"login_name": req.LoginName, "reason": "locked"})
+		res.Code = codes.CODE_ERR_LOGIN_LOCKED
+		res.Msg = fmt.Sprintf("too many failed attempts, try again in %d seconds", int(wait.Seconds())+1)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	db := system.GetDb()
 
 	var userInfo model.UserInfo
@@ -162,50 +269,42 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// unknown names and wrong passwords get the same answer in the same time
+	storedHash := userInfo.Password
 	if userInfo.ID == 0 {
-		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "user information is not found"
-		c.JSON(http.StatusOK, res)
-		return
+		storedHash = dummyPasswordHash()
 	}
-
-	if req.Password != userInfo.Password {
+	match, rehash := security.VerifyPassword(req.Password, storedHash)
+	if userInfo.ID == 0 || !match {
+		loginguard.Fail(account, c.ClientIP(), userInfo.ID)
+		audit.Record(c, audit.ACTION_LOGIN_FAILED, userInfo.ID, nil, gin.H{"login_name": req.LoginName, "reason": "password"})
 		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "user password is incorrect"
+		res.Msg = "login name or password is incorrect"
 		c.JSON(http.StatusOK, res)
 		return
 	}
+	loginguard.Succeed(account)
+
+	if rehash {
+		// upgrade legacy sha256 / outdated argon2id hashes in place
+		passwordHash, err := security.HashPassword(req.Password)
+		if err == nil {
+			err = db.Model(&model.UserInfo{}).Where("id = ?", userInfo.ID).
+				Updates(map[string]interface{}{"password": passwordHash, "update_time": time.Now()}).Error
+		}
+		if err != nil {
+			log.Error("rehash password error: ", userInfo.ID, err)
+		}
+	}
 
-	// if userInfo.Status != "20" {
-	// 	res.Code = codes.CODE_STATUS_INVALID
-	// 	res.Msg = "user status is invalid"
-	// 	c.JSON(http.StatusOK, res)
-	// 	return
-	// }
-
-	originalStr := fmt.Sprintf("%d,%s,%d", userInfo.ID, userInfo.UserNo, time.Now().Unix())
-	token, err := security.Encrypt([]byte(originalStr))
-
-	if err != nil {
-		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
-		res.Msg = "build login token failed"
+	if config.GetConfig().Auth.RequireEmailVerified && userInfo.Status != model.USER_STATUS_ACTIVE {
+		res.Code = codes.CODE_STATUS_INVALID
+		res.Msg = "email address is not verified"
 		c.JSON(http.StatusOK, res)
 		return
 	}
 
-	res.Code = codes.CODE_SUCCESS
-	res.Msg = "success"
-	res.Data = struct {
-		UserNo string `json:"user_no"`
-		Email  string `json:"email"`
-		Name   string `json:"name"`
-		Token  string `json:"token"`
-	}{
-		UserNo: userInfo.UserNo,
-		Email:  userInfo.Email,
-		Name:   userInfo.Name,
-		Token:  token,
-	}
+	completeLogin(c, &res, userInfo, req.Device)
 	c.JSON(http.StatusOK, res)
 }
 
@@ -225,17 +324,43 @@ func CourseFetchList(c *gin.Context) {
 
 	db := system.GetDb()
 
+	var errs validation.Errors
+	language := c.Query("language")
+	if len(language) > 0 {
+		if lang, err := validation.Language(language); errs.Check("language", err) {
+			language = lang
+		}
+	}
+	var memberID uint64
+	if v := c.Query("member_id"); len(v) > 0 {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			errs.Add("member_id", validation.CODE_INVALID, "invalid member id")
+		}
+		memberID = id
+	}
+	level, err := courseLevel(c, db, language, memberID)
+	errs.Check("level", err)
+	if !common.InvalidFields(&res, errs) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	query := db.Model(&model.CourseInfo{}).Where("status = ? AND flag != ?", "100", -1)
+	if len(language) > 0 {
+		query = query.Where("language = ?", language)
+	}
+	if level > 0 {
+		query = query.Where("level = ?", level)
+	}
+
 	var courseList []model.CourseInfo
 	var total int64
 
 	// 统计总数
-	db.Model(&model.CourseInfo{}).
-		Where("status = ? AND flag != ?", "100", -1).
-		Count(&total)
+	query.Count(&total)
 
 	// 获取当前页数据
-	err := db.Model(&model.CourseInfo{}).
-		Where("status = ? AND flag != ?", "100", -1).
+	err = query.
 		Order("id ASC").
 		Offset(int((pageNo - 1) * pageSize)).
 		Limit(int(pageSize)).
@@ -254,6 +379,8 @@ func CourseFetchList(c *gin.Context) {
 	res.Msg = "success"
 	res.Data = gin.H{
 		"list":        courseList,
+		"language":    language, // the language filtered on, "" for all
+		"level":       level,    // the level filtered on, 0 for all
 		"pn":          pageNo,
 		"ps":          pageSize,
 		"total":       total,
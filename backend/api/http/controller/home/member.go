@@ -0,0 +1,103 @@
+package home
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/family"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+)
+
+type MemberInviteAcceptRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+	Device   string `json:"device"`
+}
+
+// AcceptMemberInvite creates the member's own login from an emailed invite
+// and signs them in. The login only sees the member's lessons on the
+// guardian's account.
+func AcceptMemberInvite(c *gin.Context) {
+	var req MemberInviteAcceptRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// the token is only used up once the login can be created, so a refused
+	// invite can be retried after the guardian fixes the address
+	member, err := family.Invited(req.Token)
+	if !inviteUsable(&res, err) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var count int64
+	db.Model(&model.UserInfo{}).Where("email = ?", member.Email).Count(&count)
+	if count > 0 {
+		res.Code = codes.CODE_ERR_EXIST_OBJ
+		res.Msg = "email repeated, please ask your guardian to invite another address"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if member, err = family.Redeem(req.Token); !inviteUsable(&res, err) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var guardian model.UserInfo
+	var country model.DictCountry
+	db.Model(&model.UserInfo{}).Where("id = ?", member.UserID).First(&guardian)
+	db.Model(&model.DictCountry{}).Where("id = ?", guardian.CountryID).First(&country)
+
+	passwordHash, err := security.HashPassword(req.Password)
+	if err != nil {
+		log.Error("hash password error: ", err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "create password failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	// the invite went to this address, so it counts as verified
+	userInfo, err := createUser(db, member.Email, member.Name, passwordHash, country, model.USER_STATUS_ACTIVE, security.ROLE_MEMBER)
+	if err == nil {
+		err = family.Link(db, member, userInfo.ID)
+	}
+	if err != nil {
+		log.Error("create member login error: ", member.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "create login failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	completeLogin(c, &res, userInfo, req.Device)
+	c.JSON(http.StatusOK, res)
+}
+
+func inviteUsable(res *common.Response, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, family.ErrAlreadyInvited):
+		res.Code = codes.CODE_ERR_EXIST_OBJ
+		res.Msg = "this invite has already been used, please login"
+	default:
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "invite link invalid or expired"
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package home
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/notify"
+	"github.com/langbridge/backend/security"
+)
+
+// UnsubscribeRequest takes the parameters of the emailed link, either as
+// JSON from the web page or from the query string of the List-Unsubscribe
+// link, which mail clients post to for one-click unsubscribe (RFC 8058).
+type UnsubscribeRequest struct {
+	Event   string `json:"event" form:"event" binding:"required"`
+	Channel string `json:"channel" form:"channel" binding:"required"`
+	Token   string `json:"token" form:"token" binding:"required"`
+}
+
+// Unsubscribe turns one notification event off on one channel without a
+// login, using the token from the link.
+func Unsubscribe(c *gin.Context) {
+	var req UnsubscribeRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBind(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, err := notify.Unsubscribe(req.Event, req.Channel, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, notify.ErrUnknownEvent):
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = err.Error()
+		case errors.Is(err, security.ErrActionTokenExpired):
+			res.Code = codes.CODE_ERR_REQ_EXPIRED
+			res.Msg = "unsubscribe link expired, please change your preferences in the app"
+		case errors.Is(err, security.ErrActionTokenInvalid):
+			res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+			res.Msg = "unsubscribe link invalid"
+		default:
+			log.Error("unsubscribe error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "unsubscribe failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{"event": req.Event, "channel": req.Channel}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,376 @@
+package home
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/oidc"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+const OAUTH_STATE_TTL = 10 * time.Minute
+
+// oauthState is kept in Redis between the redirect to the provider and the
+// callback, keyed by the state parameter.
+type oauthState struct {
+	Provider   string `json:"provider"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"`
+	Binding    string `json:"binding"` // digest of the binding handed to the browser
+	CountryID  uint64 `json:"country_id"`
+	LinkUserID uint64 `json:"link_user_id"` // set when a signed-in user links an identity
+}
+
+// OAuthCallbackRequest carries the provider's code and state, and the
+// binding the start call returned. The frontend keeps the binding in session
+// storage, so a callback link from someone else's flow cannot complete here.
+type OAuthCallbackRequest struct {
+	State   string `json:"state" binding:"required"`
+	Code    string `json:"code" binding:"required"`
+	Binding string `json:"binding" binding:"required"`
+	Device  string `json:"device"`
+}
+
+var (
+	oidcProviders   = map[string]*oidc.Provider{}
+	oidcProvidersMu sync.Mutex
+)
+
+func oauthStateKey(state string) string {
+	return "lb:oidc:state:" + state
+}
+
+func oauthBindingDigest(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+// oidcProvider returns the configured provider, running discovery on first use.
+func oidcProvider(ctx context.Context, name string) (*oidc.Provider, error) {
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+	if p, ok := oidcProviders[name]; ok {
+		return p, nil
+	}
+	for _, pc := range config.GetConfig().OIDC.Providers {
+		if pc.Name != name || len(pc.ClientID) == 0 {
+			continue
+		}
+		conf := oidc.Config{
+			Name:         pc.Name,
+			Issuer:       pc.Issuer,
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  pc.RedirectURL,
+			Scopes:       pc.Scopes,
+		}
+		if len(pc.PrivateKey) > 0 {
+			key, err := oidc.ParseSecretKey(pc.TeamID, pc.KeyID, pc.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			conf.SecretKey = key
+		}
+		p, err := oidc.Discover(ctx, conf, nil)
+		if err != nil {
+			return nil, err
+		}
+		oidcProviders[name] = p
+		return p, nil
+	}
+	return nil, fmt.Errorf("oidc provider %q not configured", name)
+}
+
+func beginOAuth(c *gin.Context, res *common.Response, name string, countryID, linkUserID uint64) {
+	p, err := oidcProvider(c.Request.Context(), name)
+	if err != nil {
+		log.Error("[OAuth] provider error: ", name, err)
+		res.Code = codes.CODE_ERR_CONFIG
+		res.Msg = "login provider unavailable"
+		return
+	}
+	state, err1 := oidc.RandomString(24)
+	nonce, err2 := oidc.RandomString(24)
+	verifier, challenge, err3 := oidc.NewPKCE()
+	binding, err4 := oidc.RandomString(24)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "start login failed"
+		return
+	}
+	st := oauthState{Provider: name, Nonce: nonce, Verifier: verifier, Binding: oauthBindingDigest(binding),
+		CountryID: countryID, LinkUserID: linkUserID}
+	if err := system.ObjectSet(oauthStateKey(state), st, OAUTH_STATE_TTL); err != nil {
+		log.Error("[OAuth] save state error: ", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "start login failed"
+		return
+	}
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{"auth_url": p.AuthCodeURL(state, nonce, challenge), "binding": binding}
+}
+
+// OAuthStart returns the provider URL to send the browser to, and the
+// binding the callback must echo. An optional ?country= is used if the
+// sign-in ends up creating an account.
+func OAuthStart(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	countryID, _ := strconv.ParseUint(c.Query("country"), 10, 64)
+	beginOAuth(c, &res, c.Param("provider"), countryID, 0)
+	c.JSON(http.StatusOK, res)
+}
+
+// OAuthLinkStart is OAuthStart for a signed-in user adding a provider to
+// their account. It sits behind TokenInterceptor.
+func OAuthLinkStart(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	userIDStr, _ := c.Get("user_id")
+	currentUserStr, _ := userIDStr.(string)
+	userID, err := strconv.ParseUint(currentUserStr, 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	beginOAuth(c, &res, c.Param("provider"), 0, userID)
+	c.JSON(http.StatusOK, res)
+}
+
+// OAuthCallback completes the flow: it redeems the code, validates the ID
+// token and then links the identity, or signs in, or creates the account.
+func OAuthCallback(c *gin.Context) {
+	var req OAuthCallbackRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	ctx := c.Request.Context()
+	raw, err := system.GetRedis().GetDel(ctx, oauthStateKey(req.State)).Bytes()
+	var st oauthState
+	if err != nil || json.Unmarshal(raw, &st) != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "login session expired, please try again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(oauthBindingDigest(req.Binding)), []byte(st.Binding)) != 1 {
+		log.Error("[OAuth] state not started by this browser: ", st.Provider)
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "login session expired, please try again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	p, err := oidcProvider(ctx, st.Provider)
+	if err != nil {
+		log.Error("[OAuth] provider error: ", st.Provider, err)
+		res.Code = codes.CODE_ERR_CONFIG
+		res.Msg = "login provider unavailable"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	token, err := p.Exchange(ctx, req.Code, st.Verifier)
+	if err != nil {
+		log.Error("[OAuth] exchange error: ", st.Provider, err)
+		res.Code = codes.CODE_ERR_REMOTE
+		res.Msg = "sign in with provider failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	id, err := p.VerifyIDToken(ctx, token.IDToken, st.Nonce)
+	if err != nil {
+		log.Error("[OAuth] id token error: ", st.Provider, err)
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "sign in with provider failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	var identity model.UserIdentity
+	err = db.Model(&model.UserIdentity{}).Where("provider = ? and subject = ?", st.Provider, id.Subject).First(&identity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error("[OAuth] find identity error: ", st.Provider, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "sign in failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	if st.LinkUserID > 0 {
+		if identity.ID > 0 && identity.UserID != st.LinkUserID {
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "this account is already linked to another user"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if identity.ID == 0 {
+			if err := linkIdentity(db, st.LinkUserID, st.Provider, id); err != nil {
+				log.Error("[OAuth] link identity error: ", st.LinkUserID, err)
+				res.Code = codes.CODE_ERR_DB_ERROR
+				res.Msg = "link account failed"
+				c.JSON(http.StatusOK, res)
+				return
+			}
+		}
+		res.Code = codes.CODE_SUCCESS
+		res.Msg = "success"
+		res.Data = gin.H{"provider": st.Provider, "email": id.Email}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	if identity.ID > 0 {
+		db.Model(&model.UserInfo{}).Where("id = ?", identity.UserID).First(&userInfo)
+		db.Model(&model.UserIdentity{}).Where("id = ?", identity.ID).Update("last_login", time.Now())
+	} else {
+		var ok bool
+		if userInfo, ok = identityUser(db, st, id, &res); !ok {
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user information is not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if config.GetConfig().Auth.RequireEmailVerified && userInfo.Status != model.USER_STATUS_ACTIVE {
+		res.Code = codes.CODE_STATUS_INVALID
+		res.Msg = "email address is not verified"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	completeLogin(c, &res, userInfo, req.Device)
+	c.JSON(http.StatusOK, res)
+}
+
+// identityUser finds the account for a first-time identity: an existing,
+// verified user with the same provider-verified email, or else a new one. It fills res and
+// returns false when the sign-in cannot go on.
+func identityUser(db *gorm.DB, st oauthState, id *oidc.IDToken, res *common.Response) (model.UserInfo, bool) {
+	var userInfo model.UserInfo
+	if len(id.Email) == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "the provider did not share an email address"
+		return userInfo, false
+	}
+
+	if id.EmailVerified {
+		// only a verified address proves the same person owns both accounts,
+		// and only on both sides: an account that never verified the address
+		// may have been registered by someone else to wait for the owner
+		db.Model(&model.UserInfo{}).Where("email = ?", id.Email).First(&userInfo)
+		if userInfo.ID > 0 && userInfo.Status != model.USER_STATUS_ACTIVE {
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "email repeated, please verify your email, login and link this provider from your profile"
+			return model.UserInfo{}, false
+		}
+	} else {
+		var count int64
+		db.Model(&model.UserInfo{}).Where("email = ?", id.Email).Count(&count)
+		if count > 0 {
+			res.Code = codes.CODE_ERR_EXIST_OBJ
+			res.Msg = "email repeated, please login and link this provider from your profile"
+			return userInfo, false
+		}
+	}
+
+	if userInfo.ID == 0 {
+		country, ok := identityCountry(db, st.CountryID, id.Locale)
+		if !ok {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "Please specify country code"
+			return userInfo, false
+		}
+		name := id.Name
+		if len(name) == 0 {
+			name, _, _ = strings.Cut(id.Email, "@")
+		}
+		status := model.USER_STATUS_UNVERIFIED
+		if id.EmailVerified {
+			status = model.USER_STATUS_ACTIVE
+		}
+		var err error
+		userInfo, err = createUser(db, id.Email, name, "", country, status, security.ROLE_STUDENT)
+		if err != nil {
+			log.Error("[OAuth] create user error: ", err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "sign in failed"
+			return userInfo, false
+		}
+		if !id.EmailVerified {
+			if err := sendVerificationMail(userInfo); err != nil {
+				log.Error("send verification mail error: ", userInfo.ID, err)
+			}
+		}
+	}
+
+	if err := linkIdentity(db, userInfo.ID, st.Provider, id); err != nil {
+		log.Error("[OAuth] link identity error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "sign in failed"
+		return userInfo, false
+	}
+	return userInfo, true
+}
+
+func linkIdentity(db *gorm.DB, userID uint64, provider string, id *oidc.IDToken) error {
+	return db.Create(&model.UserIdentity{
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   id.Subject,
+		Email:     id.Email,
+		AddTime:   time.Now(),
+		LastLogin: time.Now(),
+	}).Error
+}
+
+// identityCountry picks the new account's country: the one chosen on the
+// sign-in page, else the region of the provider locale ("en-GB" -> GB).
+func identityCountry(db *gorm.DB, countryID uint64, locale string) (model.DictCountry, bool) {
+	var country model.DictCountry
+	if countryID > 0 {
+		db.Model(&model.DictCountry{}).Where("id = ?", countryID).First(&country)
+		return country, country.ID > 0
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) < 2 || len(parts[len(parts)-1]) != 2 {
+		return country, false
+	}
+	db.Model(&model.DictCountry{}).Where("iso2 = ?", strings.ToUpper(parts[len(parts)-1])).First(&country)
+	return country, country.ID > 0
+}
@@ -0,0 +1,59 @@
+package home
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/family"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/placement"
+	"gorm.io/gorm"
+)
+
+var errLevel = errors.New("level must be 1-6, a CEFR band such as B1, or all")
+
+// courseLevel picks the level CourseFetchList filters on. ?level= takes a
+// number or a band name, and "all" or 0 turns the filter off. Without it a
+// signed-in learner (or their member memberID) browsing courses in language
+// gets their placed level there; 0 means no filter.
+func courseLevel(c *gin.Context, db *gorm.DB, language string, memberID uint64) (int, error) {
+	if v, ok := c.GetQuery("level"); ok && len(v) > 0 {
+		if strings.EqualFold(v, "all") {
+			return 0, nil
+		}
+		if level, ok := placement.Level(strings.ToUpper(v)); ok {
+			return level, nil
+		}
+		level, err := strconv.Atoi(v)
+		if err != nil || level < 0 || level > len(placement.BANDS) {
+			return 0, errLevel
+		}
+		return level, nil
+	}
+	if len(language) == 0 {
+		return 0, nil
+	}
+
+	currentUser, _ := c.Get("user_id")
+	currentUserStr, _ := currentUser.(string)
+	userID, err := strconv.ParseUint(currentUserStr, 10, 64)
+	if err != nil || userID == 0 {
+		return 0, nil
+	}
+	if member, ok := family.Pinned(userID); ok {
+		userID, memberID = member.UserID, member.ID
+	} else if memberID > 0 {
+		var member model.UserMember
+		db.Model(&model.UserMember{}).Where("id = ? and user_id = ? and flag != ?", memberID, userID, -1).First(&member)
+		if member.ID == 0 {
+			return 0, nil
+		}
+	}
+	var level model.LearnerLevel
+	db.Model(&model.LearnerLevel{}).
+		Where("user_id = ? and member_id = ? and language = ?", userID, memberID, language).
+		First(&level)
+	return level.Level, nil
+}
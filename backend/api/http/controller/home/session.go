@@ -0,0 +1,309 @@
+package home
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/family"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/loginguard"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/twofactor"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LoginTokens struct {
+	UserNo       string `json:"user_no"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// issueLoginTokens opens a new device session for the user and returns its
+// access and refresh tokens. Every sign-in path ends here.
+func issueLoginTokens(c *gin.Context, userInfo model.UserInfo, device string) (*LoginTokens, error) {
+	s, refresh, err := session.Create(userInfo.ID, device, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+	token, err := accessToken(userInfo, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	audit.Record(c, audit.ACTION_LOGIN, userInfo.ID, nil, gin.H{"session": s.ID, "device": device})
+	return &LoginTokens{
+		UserNo:       userInfo.UserNo,
+		Email:        userInfo.Email,
+		Name:         userInfo.Name,
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(common.ACCESS_TOKEN_DURATION.Seconds()),
+	}, nil
+}
+
+// completeLogin finishes a sign-in whose first factor has been checked: it
+// fills res with either the login tokens or, when the account has 2FA on, a
+// challenge for Login2FA.
+func completeLogin(c *gin.Context, res *common.Response, userInfo model.UserInfo, device string) {
+	twoFactor, err := twofactor.Enabled(userInfo.ID)
+	if err != nil {
+		log.Error("check 2fa error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "login failed"
+		return
+	}
+	if twoFactor {
+		challenge, err := loginChallenge(userInfo)
+		if err != nil {
+			log.Error("issue 2fa challenge error: ", userInfo.ID, err)
+			res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+			res.Msg = "build login token failed"
+			return
+		}
+		res.Code = codes.CODE_SUCCESS
+		res.Msg = "two-factor code required"
+		res.Data = challenge
+		return
+	}
+
+	tokens, err := issueLoginTokens(c, userInfo, device)
+	if err != nil {
+		log.Error("issue login tokens error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "build login token failed"
+		return
+	}
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = tokens
+}
+
+// accessToken reloads roles and permissions on every issue, so grant changes
+// reach the user at the latest on the next refresh.
+func accessToken(userInfo model.UserInfo, sid string) (string, error) {
+	roles, perms, err := userGrants(userInfo.ID)
+	if err != nil {
+		return "", err
+	}
+	claims := security.NewClaims(userInfo.ID, userInfo.UserNo, common.ACCESS_TOKEN_DURATION)
+	claims.SessionID = sid
+	claims.Roles = roles
+	claims.Permissions = security.PermissionsFor(roles, perms)
+	return claims.Encode()
+}
+
+// userGrants returns the user's roles and extra permissions. A user without
+// roles has no permissions, except an account from before roles existed,
+// which becomes a student the first time it signs in.
+func userGrants(userID uint64) ([]string, []string, error) {
+	db := system.GetDb()
+	var roles []string
+	err := db.Model(&model.UserRole{}).Where("user_id = ? and flag != ?", userID, -1).Pluck("role", &roles).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(roles) == 0 {
+		if roles, err = legacyStudent(db, userID); err != nil {
+			return nil, nil, err
+		}
+	}
+	var perms []string
+	err = db.Model(&model.UserPermission{}).Where("user_id = ? and flag != ?", userID, -1).Pluck("permission", &perms).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, perms, nil
+}
+
+// legacyStudent grants the student role to an account that never had a role
+// row. One whose roles were all revoked keeps none, and a member login never
+// becomes a student.
+func legacyStudent(db *gorm.DB, userID uint64) ([]string, error) {
+	var count int64
+	if err := db.Model(&model.UserRole{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+	if _, ok := family.Pinned(userID); ok {
+		log.Error("[Session] member login without a role: ", userID)
+		return nil, nil
+	}
+	err := db.Save(&model.UserRole{UserID: userID, Role: security.ROLE_STUDENT, AddTime: time.Now()}).Error
+	if err != nil {
+		return nil, err
+	}
+	return []string{security.ROLE_STUDENT}, nil
+}
+
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	s, refresh, err := session.Rotate(req.RefreshToken)
+	if err != nil {
+		if err != session.ErrRefreshInvalid {
+			log.Error("rotate refresh token error: ", err)
+		}
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "refresh token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", s.UserID).First(&userInfo)
+	if userInfo.ID == 0 {
+		session.Revoke(s.UserID, s.ID)
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "refresh token invalid, please relogin"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	token, err := accessToken(userInfo, s.ID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "build login token failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = LoginTokens{
+		UserNo:       userInfo.UserNo,
+		Email:        userInfo.Email,
+		Name:         userInfo.Name,
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(common.ACCESS_TOKEN_DURATION.Seconds()),
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+const TWO_FACTOR_CHALLENGE_TTL = 5 * time.Minute
+
+type LoginChallenge struct {
+	TwoFactor bool   `json:"two_factor"`
+	Challenge string `json:"challenge"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"` // TOTP or backup code
+	Device    string `json:"device"`
+}
+
+// loginChallenge stands in for the session when the password was right but
+// the account has 2FA on; Login2FA trades it and a code for the tokens.
+func loginChallenge(userInfo model.UserInfo) (*LoginChallenge, error) {
+	challenge, err := security.IssueActionToken(security.ACTION_LOGIN_2FA, userInfo.ID, TWO_FACTOR_CHALLENGE_TTL)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginChallenge{
+		TwoFactor: true,
+		Challenge: challenge,
+		ExpiresIn: int64(TWO_FACTOR_CHALLENGE_TTL.Seconds()),
+	}, nil
+}
+
+func Login2FA(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	userID, err := security.ParseActionToken(security.ACTION_LOGIN_2FA, req.Challenge)
+	if err != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "login challenge invalid or expired, please login again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// codes are only six digits, so guesses count towards the same lockout
+	// as passwords
+	account := fmt.Sprintf("2fa:%d", userID)
+	if wait := loginguard.Locked(account, c.ClientIP()); wait > 0 {
+		audit.Record(c, audit.ACTION_LOGIN_FAILED, userID, nil, gin.H{"reason": "locked"})
+		res.Code = codes.CODE_ERR_LOGIN_LOCKED
+		res.Msg = fmt.Sprintf("too many failed attempts, try again in %d seconds", int(wait.Seconds())+1)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err := twofactor.Verify(userID, req.Code); err != nil {
+		if !errors.Is(err, twofactor.ErrCodeInvalid) && !errors.Is(err, twofactor.ErrNotEnrolled) {
+			log.Error("verify 2fa code error: ", userID, err)
+		}
+		loginguard.Fail(account, c.ClientIP(), userID)
+		audit.Record(c, audit.ACTION_LOGIN_FAILED, userID, nil, gin.H{"reason": "2fa"})
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "verification code is incorrect"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	loginguard.Succeed(account)
+
+	if first, err := system.Throttle("lb:2fa:challenge:"+tokenDigest(req.Challenge), TWO_FACTOR_CHALLENGE_TTL); err != nil || !first {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "login challenge invalid or expired, please login again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "login challenge invalid or expired, please login again"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	tokens, err := issueLoginTokens(c, userInfo, req.Device)
+	if err != nil {
+		log.Error("issue login tokens error: ", userInfo.ID, err)
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "build login token failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = tokens
+	c.JSON(http.StatusOK, res)
+}
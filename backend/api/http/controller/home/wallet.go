@@ -1 +1,68 @@
 package home
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/walletauth"
+)
+
+type WalletLoginRequest struct {
+	ID     uint64 `json:"id" binding:"required,min=1"` // auth message from /preauth/get_msg
+	Sign   string `json:"sign" binding:"required"`
+	Device string `json:"device"`
+}
+
+// WalletLogin signs in to the account a wallet has been linked to, using a
+// signed /preauth/get_msg challenge in place of the password.
+func WalletLogin(c *gin.Context) {
+	var req WalletLoginRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	wallet, err := walletauth.Verify(req.ID, req.Sign)
+	if err != nil {
+		audit.Record(c, audit.ACTION_LOGIN_FAILED, 0, nil, gin.H{"reason": "wallet"})
+		res.Code, res.Msg = walletauth.ErrorCode(err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	userID, err := walletauth.Owner(wallet)
+	if err != nil {
+		res.Code, res.Msg = walletauth.ErrorCode(err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ?", userID).First(&userInfo)
+	if userInfo.ID == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user information is not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if config.GetConfig().Auth.RequireEmailVerified && userInfo.Status != model.USER_STATUS_ACTIVE {
+		res.Code = codes.CODE_STATUS_INVALID
+		res.Msg = "email address is not verified"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	completeLogin(c, &res, userInfo, req.Device)
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,221 @@
+package teacher
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/photo"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/validation"
+	"gorm.io/gorm"
+)
+
+// Profile returns the teacher_info record linked to the signed-in account.
+func Profile(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	teacher, ok := currentTeacher(c, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = teacher
+	c.JSON(http.StatusOK, res)
+}
+
+type UpdateProfileRequest struct {
+	Introduction    *string `json:"introduction"`
+	Detail          *string `json:"detail"`
+	FirstLanguage   string  `json:"first_language"`
+	LivingCountryID uint64  `json:"living_country_id"`
+	Phone           string  `json:"phone"`
+}
+
+// UpdateProfile lets a teacher edit their own teacher_info record. Fields
+// left out are kept; every field sent is validated before anything is saved.
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	teacher, ok := currentTeacher(c, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+	before := *teacher
+	var errs validation.Errors
+	if req.Introduction != nil {
+		errs.MaxLen("introduction", *req.Introduction, 500)
+		teacher.Introduction = *req.Introduction
+	}
+	if req.Detail != nil {
+		errs.MaxLen("detail", *req.Detail, 5000)
+		teacher.Detail = *req.Detail
+	}
+	if len(req.FirstLanguage) > 0 {
+		if lang, err := validation.Language(req.FirstLanguage); errs.Check("first_language", err) {
+			teacher.FirstLanguage = lang
+		}
+	}
+	if req.LivingCountryID > 0 {
+		var country model.DictCountry
+		db.Model(&model.DictCountry{}).Where("id = ?", req.LivingCountryID).First(&country)
+		if country.ID > 0 {
+			teacher.LivingCountryID = country.ID
+			teacher.LivingCountryName = country.Name
+			teacher.PhoneCode = country.PhoneCode
+		} else {
+			errs.Add("living_country_id", validation.CODE_INVALID, "unknown country")
+		}
+	}
+	if len(req.Phone) > 0 {
+		if phone, err := validation.Phone(req.Phone, teacher.PhoneCode); errs.Check("phone", err) {
+			teacher.Phone = phone
+		}
+	}
+	if !common.InvalidFields(&res, errs) {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	teacher.UpdateTime = time.Now()
+	err := db.Model(&model.Teacher{}).Where("id = ?", teacher.ID).Updates(map[string]interface{}{
+		"introduction":        teacher.Introduction,
+		"detail":              teacher.Detail,
+		"first_language":      teacher.FirstLanguage,
+		"living_country_id":   teacher.LivingCountryID,
+		"living_country_name": teacher.LivingCountryName,
+		"phone_code":          teacher.PhoneCode,
+		"phone":               teacher.Phone,
+		"update_time":         teacher.UpdateTime,
+	}).Error
+	if err != nil {
+		log.Error("update teacher profile error: ", teacher.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "update profile failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	audit.Record(c, audit.ACTION_PROFILE_UPDATE, teacher.UserID, before, teacher)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = teacher
+	c.JSON(http.StatusOK, res)
+}
+
+// Slots returns the weekly time slot template of the signed-in teacher.
+func Slots(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	teacher, ok := currentTeacher(c, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var slots []model.TeacherTimeSlotTemplate
+	err := system.GetDb().Model(&model.TeacherTimeSlotTemplate{}).
+		Where("teacher_id = ?", teacher.ID).
+		Order("week_day asc, start_time asc").
+		Find(&slots).Error
+	if err != nil {
+		log.Error("load teacher slots error: ", teacher.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "load slots failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = slots
+	c.JSON(http.StatusOK, res)
+}
+
+// UploadPhoto replaces the signed-in teacher's profile photo with a
+// multipart "file", processed the same way as user avatars.
+func UploadPhoto(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	teacher, ok := currentTeacher(c, &res)
+	if !ok {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	uploaded, err := photo.Upload(c, photo.TeacherDir(teacher.ID))
+	if err != nil {
+		common.UploadFailed(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	before := teacher.Photo
+	err = system.GetDb().Model(&model.Teacher{}).Where("id = ?", teacher.ID).
+		Updates(map[string]interface{}{"photo": uploaded.URL, "update_time": time.Now()}).Error
+	if err != nil {
+		photo.Discard(c.Request.Context(), photo.TeacherDir(teacher.ID), uploaded.URL)
+		log.Error("save teacher photo error: ", teacher.ID, err)
+		res.Code = codes.CODE_ERR_DB_ERROR
+		res.Msg = "save photo failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	photo.Discard(c.Request.Context(), photo.TeacherDir(teacher.ID), before)
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = uploaded
+	c.JSON(http.StatusOK, res)
+}
+
+func currentTeacher(c *gin.Context, res *common.Response) (*model.Teacher, bool) {
+	userIDStr, _ := c.Get("user_id")
+	currentUserStr, _ := userIDStr.(string)
+	userID, err := strconv.ParseUint(currentUserStr, 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_AUTHTOKEN_FAIL
+		res.Msg = "token invalid, please relogin"
+		return nil, false
+	}
+
+	var teacher model.Teacher
+	err = system.GetDb().Model(&model.Teacher{}).Where("user_id = ? and flag != ?", userID, -1).First(&teacher).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "no teacher linked to this account"
+		} else {
+			log.Error("find teacher error: ", userID, err)
+			res.Code = codes.CODE_ERR_DB_ERROR
+			res.Msg = "find teacher failed"
+		}
+		return nil, false
+	}
+	return &teacher, true
+}
@@ -3,9 +3,13 @@ package http
 import (
 	"github.com/gin-gonic/gin"
 
+	"github.com/langbridge/backend/api/http/controller/admin"
 	"github.com/langbridge/backend/api/http/controller/auth"
 	"github.com/langbridge/backend/api/http/controller/home"
+	preauth "github.com/langbridge/backend/api/http/controller/preauth"
+	"github.com/langbridge/backend/api/http/controller/teacher"
 	"github.com/langbridge/backend/api/interceptor"
+	"github.com/langbridge/backend/security"
 )
 
 func Routers(e *gin.RouterGroup) {
@@ -16,30 +20,93 @@ func Routers(e *gin.RouterGroup) {
 	homeGroup.GET("/welcome", home.Welcome)
 	homeGroup.POST("/register", home.Register)
 	homeGroup.POST("/login", home.Login)
+	homeGroup.POST("/login/2fa", home.Login2FA)
+	homeGroup.POST("/login/wallet", home.WalletLogin)
+	homeGroup.GET("/oauth/:provider/start", home.OAuthStart)
+	homeGroup.POST("/oauth/callback", home.OAuthCallback)
+	homeGroup.POST("/register/verify", home.VerifyEmail)
+	homeGroup.POST("/register/resend", home.ResendVerification)
+	homeGroup.POST("/password/forgot", home.ForgotPassword)
+	homeGroup.POST("/password/reset", home.ResetPassword)
+	homeGroup.POST("/member/invite/accept", home.AcceptMemberInvite)
+	homeGroup.POST("/token/refresh", home.RefreshToken)
 	homeGroup.GET("/course/fetch", home.CourseFetchList)
 	homeGroup.GET("/course/detail", home.CourseFetchDetail)
 	homeGroup.GET("/course/teachers", home.CourseFetchTeacherList)
 	homeGroup.GET("/course/reviews", home.CourseFetchReviewList)
 	homeGroup.GET("/course/teacher/slots", home.CourseFetchTeacherTimeSlot)
+	homeGroup.POST("/notify/unsubscribe", home.Unsubscribe)
+
+	// wallets sign the message from get_msg and then log in or link through
+	// /login/wallet and /auth/wallet/link
+	preAuthGroup := e.Group("/preauth")
+	preAuthGroup.POST("get_msg", preauth.GetAuthMsg)
 
 	authGroup := e.Group("/auth", interceptor.TokenInterceptor())
 	authGroup.POST("/profile/retrieve", auth.RetrieveProfile)
 	authGroup.POST("/profile/update", auth.UpdateProfile)
-	authGroup.POST("/profile/member/list", auth.FetchMemberList)
-	authGroup.POST("/profile/member/add", auth.FetchMemberAdd)
-	authGroup.GET("/profile/member/del", auth.FetchMemberDelete)
-	authGroup.GET("/course/join", auth.CourseJoin)
+	authGroup.POST("/profile/avatar", auth.UploadAvatar)
+	authGroup.POST("/profile/password", auth.ChangePassword)
+	authGroup.POST("/2fa/enroll", auth.TwoFactorEnroll)
+	authGroup.POST("/2fa/enable", auth.TwoFactorEnable)
+	authGroup.POST("/2fa/disable", auth.TwoFactorDisable)
+	authGroup.GET("/oauth/:provider/link", home.OAuthLinkStart)
+	authGroup.GET("/identities", auth.IdentityList)
+	authGroup.POST("/identities/unlink", auth.IdentityUnlink)
+	authGroup.POST("/wallet/link", auth.WalletLink)
+	authGroup.POST("/wallet/unlink", auth.WalletUnlink)
+	authGroup.GET("/wallet/list", auth.WalletList)
+	authGroup.GET("/account/export", auth.AccountExport)
+	authGroup.POST("/account/delete", auth.AccountDelete)
+	authGroup.POST("/account/delete/cancel", auth.AccountDeleteCancel)
+
+	authGroup.POST("/logout", auth.Logout)
+	authGroup.GET("/sessions", auth.SessionList)
+	authGroup.POST("/sessions/revoke", auth.SessionRevoke)
+	authGroup.POST("/profile/member/list", interceptor.RequirePermission(security.PERM_FAMILY_MANAGE), auth.FetchMemberList)
+	authGroup.POST("/profile/member/add", interceptor.RequirePermission(security.PERM_FAMILY_MANAGE), auth.FetchMemberAdd)
+	authGroup.GET("/profile/member/del", interceptor.RequirePermission(security.PERM_FAMILY_MANAGE), auth.FetchMemberDelete)
+	authGroup.POST("/profile/member/invite", interceptor.RequirePermission(security.PERM_FAMILY_MANAGE), auth.FetchMemberInvite)
+	authGroup.POST("/profile/member/booking", interceptor.RequirePermission(security.PERM_FAMILY_MANAGE), auth.FetchMemberBooking)
+	authGroup.GET("/course/join", interceptor.RequirePermission(security.PERM_COURSE_BOOK), auth.CourseJoin)
 	authGroup.GET("/course/list", auth.CourseList)
-	authGroup.POST("/course/confirm", auth.CourseConfirm)
+	authGroup.POST("/course/confirm", interceptor.RequirePermission(security.PERM_COURSE_BOOK), auth.CourseConfirm)
 	authGroup.GET("/course/time/list", auth.CourseTimeList)
 	authGroup.GET("/course/time/range", auth.CourseTimeRange)
 	authGroup.GET("/course/meeting/fetch", auth.CourseGetMeetingInfo)
+	authGroup.POST("/placement/start", auth.PlacementStart)
+	authGroup.POST("/placement/answer", auth.PlacementAnswer)
+	authGroup.GET("/placement/result", auth.PlacementResult)
+	authGroup.GET("/progress", auth.Progress)
+	authGroup.POST("/progress/goal/save", auth.GoalSave)
+	authGroup.POST("/progress/goal/delete", auth.GoalDelete)
+	authGroup.GET("/notify/preferences", auth.NotifyPreferences)
+	authGroup.POST("/notify/preferences", auth.NotifyPreferencesUpdate)
+	authGroup.GET("/notify/list", auth.NotifyList)
+	authGroup.POST("/notify/read", auth.NotifyRead)
+
+	teacherGroup := e.Group("/teacher", interceptor.TokenInterceptor(), interceptor.RequireRole(security.ROLE_TEACHER))
+	teacherGroup.GET("/profile", interceptor.RequirePermission(security.PERM_TEACHER_PROFILE), teacher.Profile)
+	teacherGroup.POST("/profile/update", interceptor.RequirePermission(security.PERM_TEACHER_PROFILE), teacher.UpdateProfile)
+	teacherGroup.POST("/photo", interceptor.RequirePermission(security.PERM_TEACHER_PROFILE), teacher.UploadPhoto)
+	teacherGroup.GET("/slots", interceptor.RequirePermission(security.PERM_TEACHER_SCHEDULE), teacher.Slots)
+
+	adminGroup := e.Group("/admin", interceptor.TokenInterceptor(), interceptor.RequireRole(security.ROLE_ADMIN))
+	adminGroup.POST("/user/role", interceptor.RequirePermission(security.PERM_ADMIN_USERS), admin.UserRoleUpdate)
+	adminGroup.POST("/teacher/link", interceptor.RequirePermission(security.PERM_ADMIN_USERS), admin.TeacherLink)
+	adminGroup.GET("/lockouts", interceptor.RequirePermission(security.PERM_ADMIN_LOCKOUTS), admin.LockoutList)
+	adminGroup.POST("/lockouts/clear", interceptor.RequirePermission(security.PERM_ADMIN_LOCKOUTS), admin.LockoutClear)
+	adminGroup.GET("/audit", interceptor.RequirePermission(security.PERM_ADMIN_AUDIT), admin.AuditList)
+	adminGroup.GET("/channels", interceptor.RequirePermission(security.PERM_ADMIN_CHANNELS), admin.ChannelList)
+	adminGroup.POST("/channels/create", interceptor.RequirePermission(security.PERM_ADMIN_CHANNELS), admin.ChannelCreate)
+	adminGroup.POST("/channels/rotate", interceptor.RequirePermission(security.PERM_ADMIN_CHANNELS), admin.ChannelRotate)
+	adminGroup.POST("/channels/disable", interceptor.RequirePermission(security.PERM_ADMIN_CHANNELS), admin.ChannelDisable)
+	adminGroup.GET("/placement/items", interceptor.RequirePermission(security.PERM_ADMIN_PLACEMENT), admin.PlacementItemList)
+	adminGroup.POST("/placement/items/save", interceptor.RequirePermission(security.PERM_ADMIN_PLACEMENT), admin.PlacementItemSave)
+	adminGroup.POST("/placement/items/delete", interceptor.RequirePermission(security.PERM_ADMIN_PLACEMENT), admin.PlacementItemDelete)
 
 	// homeGroup.GET("/search/:key", home.Search)
 	// homeGroup.POST("/trans/quote", auth.Quote)
-	// preAuthGroup := e.Group("/preauth")
-	// preAuthGroup.POST("get_msg", preauth.GetAuthMsg)
-	// preAuthGroup.POST("verify_msg", preauth.VerifyMessage)
 
 	// authGroup.POST("ref_uri", auth.Ref)
 	// authGroup.POST("/ref/stat", auth.RefCount)
@@ -69,5 +136,4 @@ func Routers(e *gin.RouterGroup) {
 	// v2AuthGroup.GET("/asset-token/trans", authv2.AssetTokenTrans)
 	// v2AuthGroup.GET("/asset/list", authv2.AssetList)
 
-	// log.Info(preAuthGroup, authGroup)
 }
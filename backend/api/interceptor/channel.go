@@ -0,0 +1,89 @@
+package interceptor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+)
+
+// timeRange is how long, in seconds, channels are cached. Admin changes are
+// also broadcast on codes.TOPIC_CHANNEL_CHANGED, which drops the cache at
+// once; the expiry only matters if that message is missed.
+const timeRange = 60
+
+type channelCache struct {
+	keys  []model.SysChannel
+	valid int64
+}
+
+var (
+	cacheKeys = map[string]channelCache{}
+	wg        sync.Mutex
+)
+
+func queryKeys(channel string) []model.SysChannel {
+	wg.Lock()
+	cached, ok := cacheKeys[channel]
+	wg.Unlock()
+	if ok && time.Now().Unix()-cached.valid <= timeRange {
+		return cached.keys
+	}
+
+	db := system.GetDb()
+	var result []model.SysChannel
+	err := db.Model(&model.SysChannel{}).Where("status = ? and chan = ?", model.CHANNEL_STATUS_ACTIVE, channel).Find(&result).Error
+	if err != nil {
+		log.Error("Channel Query Error:", err)
+		return cached.keys
+	}
+
+	wg.Lock()
+	cacheKeys[channel] = channelCache{keys: result, valid: time.Now().Unix()}
+	wg.Unlock()
+	return result
+}
+
+func findChannel(channel, appID string) *model.SysChannel {
+	for _, v := range queryKeys(channel) {
+		if v.AppID == appID {
+			return &v
+		}
+	}
+	return nil
+}
+
+func invalidateChannels() {
+	wg.Lock()
+	cacheKeys = map[string]channelCache{}
+	wg.Unlock()
+}
+
+// watchRetry is how long WatchChannels waits before subscribing again.
+const watchRetry = 5 * time.Second
+
+// WatchChannels drops the channel cache whenever an instance announces a
+// channel change. It blocks; run it in its own goroutine. Without Redis it
+// returns, and changes take effect as the cache expires.
+func WatchChannels() {
+	if system.GetRedis() == nil {
+		log.Info("[Channel] no redis, channel changes apply within ", timeRange, "s")
+		return
+	}
+	for {
+		pubsub := system.GetRedis().Subscribe(context.Background(), codes.TOPIC_CHANNEL_CHANGED)
+		for msg := range pubsub.Channel() {
+			log.Info("[Channel] changed, reloading: ", msg.Payload)
+			invalidateChannels()
+		}
+		pubsub.Close()
+		// changes may have been missed while the subscription was down
+		log.Error("[Channel] change subscription closed, subscribing again")
+		invalidateChannels()
+		time.Sleep(watchRetry)
+	}
+}
@@ -0,0 +1,58 @@
+package interceptor
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+)
+
+var (
+	errTokenRevoked = errors.New("token revoked")
+	errDevIdentity  = errors.New("dev identity unavailable")
+)
+
+// authenticate turns an XAUTH header value into verified claims.
+func authenticate(xauth string) (*security.Claims, error) {
+	if strings.HasPrefix(xauth, security.DEV_TOKEN_PREFIX) {
+		if claims, ok := security.DevIdentity(xauth); ok {
+			return claims, nil
+		}
+		return nil, errDevIdentity
+	}
+	claims, err := security.ParseClaims(xauth, common.TOKEN_DURATION)
+	if err != nil {
+		return nil, err
+	}
+	// issue times are in whole seconds, so a token from the same second as
+	// the revocation is refused too
+	if claims.UserID > 0 && claims.IssuedAt <= session.TokensRevokedAt(claims.UserID) {
+		return nil, errTokenRevoked
+	}
+	if len(claims.SessionID) > 0 && !session.Active(claims.UserID, claims.SessionID) {
+		return nil, errTokenRevoked
+	}
+	if claims.UserID > 0 && len(claims.Roles) == 0 {
+		// issued before roles existed
+		claims.Roles = []string{security.ROLE_STUDENT}
+		claims.Permissions = security.PermissionsFor(claims.Roles, nil)
+	}
+	return claims, nil
+}
+
+// setClaims exposes the caller identity to handlers under the context keys
+// they already read: user_id, user_no and user_wallet.
+func setClaims(c *gin.Context, claims *security.Claims) {
+	c.Set("claims", claims)
+	if claims.UserID > 0 {
+		c.Set("user_id", strconv.FormatUint(claims.UserID, 10))
+		c.Set("user_no", claims.UserNo)
+	}
+	if len(claims.Wallet) > 0 {
+		c.Set("user_wallet", claims.Wallet)
+	}
+}
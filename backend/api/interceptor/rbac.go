@@ -0,0 +1,42 @@
+package interceptor
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/security"
+)
+
+// RequireRole lets the request through when the caller holds any of roles.
+// It must run after TokenInterceptor.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := callerClaims(c)
+		if !ok || !claims.HasRole(roles...) {
+			makeFaileRes(c, codes.CODE_ERR_FORBIDDEN, "permission denied")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission lets the request through when the caller has perm,
+// through a role or a direct grant. It must run after TokenInterceptor.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := callerClaims(c)
+		if !ok || !claims.HasPermission(perm) {
+			makeFaileRes(c, codes.CODE_ERR_FORBIDDEN, "permission denied")
+			return
+		}
+		c.Next()
+	}
+}
+
+func callerClaims(c *gin.Context) (*security.Claims, bool) {
+	v, ok := c.Get("claims")
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*security.Claims)
+	return claims, ok && claims.UserID > 0
+}
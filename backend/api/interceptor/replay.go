@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+)
+
+const (
+	defaultMaxSkew  = 5 * time.Minute
+	defaultNonceTTL = 10 * time.Minute
+)
+
+// checkReplay refuses signed requests that are stale or reuse a REQUESTID
+// already seen for the same app. Call it only after the signature verified,
+// so unsigned traffic cannot burn request ids.
+func checkReplay(hp common.HeaderParam) (bool, int64, string) {
+	conf := config.GetConfig().Signature
+	maxSkew := time.Duration(conf.MaxSkew) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxSkew
+	}
+	nonceTTL := time.Duration(conf.NonceTTL) * time.Second
+	if nonceTTL < 2*maxSkew {
+		nonceTTL = max(defaultNonceTTL, 2*maxSkew)
+	}
+
+	if err := security.CheckRequestTime(hp.Ts, time.Now(), maxSkew); err != nil {
+		return false, codes.CODE_ERR_REQ_EXPIRED, "request expired"
+	}
+	if len(hp.RequestId) == 0 {
+		return false, codes.CODE_ERR_PARA_EMPTY, "empty request id"
+	}
+
+	fresh, err := system.Throttle(fmt.Sprintf("lb:nonce:%s:%s", hp.AppId, hp.RequestId), nonceTTL)
+	if err != nil {
+		log.Error("request nonce store error: ", err)
+		return false, codes.CODE_ERR_UNKNOWN, "request check failed"
+	}
+	if !fresh {
+		return false, codes.CODE_ERR_REPEAT, "request repeated"
+	}
+	return true, codes.CODE_SUCCESS, ""
+}
@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+)
+
+// signed bodies are read into memory to be hashed
+const maxSignedBody = 20 << 20
+
+var errBodyTooLarge = errors.New("request body too large to sign")
+
+// signedRequest collects what the channel's signature covers. The body is
+// only read for methods that hash it, and is put back for the handlers.
+func signedRequest(c *gin.Context, hp common.HeaderParam, channel *model.SysChannel) (security.SignedRequest, error) {
+	req := security.SignedRequest{
+		AppID:     hp.AppId,
+		RequestID: hp.RequestId,
+		Ts:        hp.Ts,
+		Ver:       hp.Ver,
+		Method:    c.Request.Method,
+		URI:       c.Request.URL.RequestURI(),
+	}
+	if channel.SigMethod == security.SIG_SHA256 {
+		return req, nil
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBody+1))
+		if err != nil {
+			return req, err
+		}
+		if len(body) > maxSignedBody {
+			return req, errBodyTooLarge
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	req.BodyHash = security.HashBody(body)
+	return req, nil
+}
@@ -1,16 +1,18 @@
 package interceptor
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/audit"
 	"github.com/langbridge/backend/codes"
 	"github.com/langbridge/backend/log"
 	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
 )
 
 func TokenInterceptor() gin.HandlerFunc {
@@ -22,40 +24,47 @@ func TokenInterceptor() gin.HandlerFunc {
 			return
 		}
 		allHeadersMap := allHeaders.(common.HeaderParam)
-		if allHeadersMap.XAuth == "123456" {
-			c.Set("user_wallet", "0x0")
-			c.Set("user_id", "1")
-			c.Next()
-			return
-		}
-		token, err := security.Decrypt(c.Request.Header.Get("XAUTH"))
-		log.Info("TOKENCHECK ", c.Request.Header.Get("XAUTH"), token)
-		if err != nil {
-			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token check failed")
-			return
-		}
-		tokenArr := strings.Split(token, ",")
-		if len(tokenArr) != 3 {
-			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token length error")
-			return
-		}
-		expireTs, err := strconv.ParseInt(tokenArr[2], 10, 64)
+		claims, err := authenticate(allHeadersMap.XAuth)
 		if err != nil {
-			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token format error")
+			switch {
+			case errors.Is(err, security.ErrTokenExpired):
+				// routine once the access token runs out, not worth an audit entry
+				makeFaileRes(c, codes.CODE_ERR_SECURITY, "token expired error")
+			case errors.Is(err, errTokenRevoked):
+				auditTokenInvalid(c, "revoked")
+				makeFaileRes(c, codes.CODE_ERR_SECURITY, "token revoked, please relogin")
+			case errors.Is(err, security.ErrTokenMalformed):
+				auditTokenInvalid(c, "malformed")
+				makeFaileRes(c, codes.CODE_ERR_SECURITY, "token format error")
+			default:
+				log.Info("token check failed: ", err)
+				auditTokenInvalid(c, "decrypt")
+				makeFaileRes(c, codes.CODE_ERR_SECURITY, "token check failed")
+			}
 			return
 		}
-		if time.Now().Unix()-expireTs > int64(common.TOKEN_DURATION.Seconds()) {
-			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token expired error")
+		if claims.UserID == 0 {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token check failed")
 			return
 		}
-
-		c.Set("user_no", tokenArr[1])
-		c.Set("user_id", tokenArr[0])
+		setClaims(c, claims)
 
 		c.Next()
 	}
 }
 
+// TOKEN_AUDIT_WINDOW limits token_invalid events to one per client IP and
+// reason, so a client replaying a bad token cannot flood the audit log.
+const TOKEN_AUDIT_WINDOW = time.Minute
+
+func auditTokenInvalid(c *gin.Context, reason string) {
+	first, err := system.Throttle(fmt.Sprintf("lb:audit:token:%s:%s", c.ClientIP(), reason), TOKEN_AUDIT_WINDOW)
+	if err != nil || !first {
+		return
+	}
+	audit.Record(c, audit.ACTION_TOKEN_INVALID, 0, nil, gin.H{"reason": reason})
+}
+
 func makeFaileRes(c *gin.Context, code int64, msg string) {
 	c.Abort()
 	c.JSON(http.StatusOK, common.Response{
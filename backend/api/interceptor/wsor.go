@@ -2,7 +2,6 @@ package interceptor
 
 import (
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -10,25 +9,30 @@ import (
 	"github.com/langbridge/backend/api/common"
 	"github.com/langbridge/backend/codes"
 	"github.com/langbridge/backend/log"
-	"github.com/langbridge/backend/model"
 	"github.com/langbridge/backend/security"
 )
 
 func WSInterceptor() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Query("X-Token") == "123456" {
-			c.Next() // for test
+		// browsers cannot set headers on a websocket upgrade, so dev
+		// deployments may pass a dev identity in the query instead
+		if xToken := c.Query("X-Token"); strings.HasPrefix(xToken, security.DEV_TOKEN_PREFIX) {
+			if claims, ok := security.DevIdentity(xToken); ok {
+				setClaims(c, claims)
+				c.Next()
+				return
+			}
+			c.Abort()
+			c.JSON(http.StatusOK, common.Response{
+				Code:      codes.CODE_ERR_SECURITY,
+				Msg:       "dev identity unavailable",
+				Timestamp: time.Now().Unix(),
+			})
 			return
 		}
-		queryKeys(WS)
 		hp := parse(&c.Request.Header)
 
-		var targetChannel *model.SysChannel
-		for _, v := range cacheKeys {
-			if v.AppID == hp.AppId {
-				targetChannel = &v
-			}
-		}
+		targetChannel := findChannel(WS, hp.AppId)
 		if targetChannel == nil {
 			c.Abort()
 			c.JSON(http.StatusOK, common.Response{
@@ -38,7 +42,17 @@ func WSInterceptor() gin.HandlerFunc {
 			})
 			return
 		}
-		if ok, code := targetChannel.Verify(hp.Join(), hp.AuthToken); !ok {
+		signed, err := signedRequest(c, hp, targetChannel)
+		if err != nil {
+			c.Abort()
+			c.JSON(http.StatusOK, common.Response{
+				Code:      codes.CODE_ERR_REQFORMAT,
+				Msg:       "invalid request" + err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+		if ok, code := targetChannel.Verify(signed, hp.AuthToken); !ok {
 			c.Abort()
 			c.JSON(http.StatusOK, common.Response{
 				Code:      int64(code),
@@ -47,24 +61,24 @@ func WSInterceptor() gin.HandlerFunc {
 			})
 			return
 		}
+		if ok, code, msg := checkReplay(hp); !ok {
+			c.Abort()
+			c.JSON(http.StatusOK, common.Response{
+				Code:      code,
+				Msg:       msg,
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
 		c.Set("APPID", hp.AppId)
 		c.Set("REQUESTID", hp.RequestId)
 		c.Set("TS", hp.Ts)
 		c.Set("HEADERS", hp)
 
-		token, err := security.Decrypt(hp.XAuth)
-		if err == nil {
-			tokenArr := strings.Split(token, "|")
-			if len(tokenArr) == 4 {
-				expireTs, err := strconv.ParseInt(tokenArr[3], 10, 64)
-				if err == nil {
-					if time.Now().Unix()-expireTs <= int64(common.TOKEN_DURATION.Seconds()) {
-						c.Set("user_wallet", tokenArr[1])
-						c.Set("user_id", tokenArr[0])
-					}
-				}
+		if len(hp.XAuth) > 0 {
+			if claims, err := authenticate(hp.XAuth); err == nil {
+				setClaims(c, claims)
 			}
-
 		}
 		c.Next()
 
@@ -39,8 +39,13 @@ func Init() *gin.Engine {
 
 	r.GET("/index", helloHandler) //Default welcome api
 
+	if storageConf := config.GetConfig().Storage; storageConf.Backend == "local" && len(storageConf.Local.URLPath) > 0 {
+		r.Static(storageConf.Local.URLPath, storageConf.Local.Dir)
+	}
+
 	wsGroup := r.Group("/ws", interceptor.WSInterceptor())
 	wsGroup.GET("chat", ws.Chat)
+	wsGroup.GET("notify", ws.Notify)
 
 	apiGroup := r.Group("/spwapi", interceptor.HttpInterceptor()) // total interceptor stack
 	for _, opt := range options {
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/notify"
+)
+
+// Notify streams the signed-in user's notifications as they happen. The
+// client sends "ping" as a heartbeat; everything else it sends is ignored.
+func Notify(c *gin.Context) {
+	currentUser, _ := c.Get("user_id")
+	currentUserStr, _ := currentUser.(string)
+	userID, err := strconv.ParseUint(currentUserStr, 10, 64)
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusOK, common.Response{
+			Code:      codes.CODE_ERR_AUTHTOKEN_FAIL,
+			Msg:       "token invalid, please relogin",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	ws, err := upgrade.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error("ws notify upgrade error: ", err)
+		return
+	}
+	defer ws.Close()
+
+	client := notify.Attach(userID, ws)
+	defer notify.Detach(client)
+
+	for {
+		mt, message, err := ws.ReadMessage()
+		if err != nil {
+			break
+		}
+		if string(message) == "ping" { //heart beat
+			if err := client.WriteMessage(mt, []byte("pong")); err != nil {
+				break
+			}
+		}
+	}
+}
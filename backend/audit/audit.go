@@ -0,0 +1,103 @@
+// Package audit appends security-relevant events to the audit_event table.
+// It has no update or delete path; the log is append-only.
+package audit
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/audit/diff"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+)
+
+const (
+	ACTION_LOGIN           = "login"
+	ACTION_LOGIN_FAILED    = "login_failed"
+	ACTION_TOKEN_INVALID   = "token_invalid"
+	ACTION_PROFILE_UPDATE  = "profile_update"
+	ACTION_MEMBER_ADD      = "member_add"
+	ACTION_MEMBER_UPDATE   = "member_update"
+	ACTION_MEMBER_DELETE   = "member_delete"
+	ACTION_MEMBER_INVITE   = "member_invite"
+	ACTION_MEMBER_BOOKING  = "member_booking"
+	ACTION_BOOKING_CONFIRM = "booking_confirm"
+	ACTION_CHANNEL_CREATE  = "channel_create"
+	ACTION_CHANNEL_ROTATE  = "channel_rotate"
+	ACTION_CHANNEL_DISABLE = "channel_disable"
+	ACTION_PLACEMENT_ITEM  = "placement_item"
+)
+
+// Record appends an event about userID, taking the actor, IP, AppID and
+// REQUESTID from the request. before and after are diffed field by field;
+// pass nil for the side that does not exist. Failures are logged, never
+// returned, so auditing cannot break the request it describes.
+func Record(c *gin.Context, action string, userID uint64, before, after any) {
+	event := model.AuditEvent{
+		ActorID:   actor(c),
+		UserID:    userID,
+		Action:    action,
+		IP:        c.ClientIP(),
+		AppID:     c.GetString("APPID"),
+		RequestID: c.GetString("REQUESTID"),
+		AddTime:   time.Now(),
+	}
+	if before != nil || after != nil {
+		changes, err := diff.Compare(before, after)
+		if err == nil {
+			event.Diff, err = json.Marshal(changes)
+		}
+		if err != nil {
+			log.Error("[Audit] diff error: ", action, userID, err)
+		}
+	}
+	if err := system.GetDb().Create(&event).Error; err != nil {
+		log.Error("[Audit] record error: ", action, userID, err)
+	}
+}
+
+func actor(c *gin.Context) uint64 {
+	id, _ := strconv.ParseUint(c.GetString("user_id"), 10, 64)
+	return id
+}
+
+type Query struct {
+	UserID   uint64
+	Action   string
+	From     time.Time
+	To       time.Time
+	PageNo   int64
+	PageSize int64
+}
+
+// Find pages through events, newest first. A user matches as the account
+// acted on or as the actor.
+func Find(q Query) ([]model.AuditEvent, int64, error) {
+	query := system.GetDb().Model(&model.AuditEvent{})
+	if q.UserID > 0 {
+		query = query.Where("(user_id = ? or actor_id = ?)", q.UserID, q.UserID)
+	}
+	if len(q.Action) > 0 {
+		query = query.Where("action = ?", q.Action)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("add_time >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("add_time < ?", q.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []model.AuditEvent
+	err := query.Order("id DESC").
+		Offset(int((q.PageNo - 1) * q.PageSize)).
+		Limit(int(q.PageSize)).
+		Find(&list).Error
+	return list, total, err
+}
@@ -0,0 +1,54 @@
+// Package diff compares two versions of a record for the audit log.
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// FieldChange is one field of an audit diff.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Compare compares two values through their JSON form and returns the top-level
+// fields that differ. Fields hidden from JSON, such as password hashes, never
+// show up. Either side may be nil, for creations and deletions.
+func Compare(before, after any) (map[string]FieldChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]FieldChange{}
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[k] = FieldChange{Before: bv, After: a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = FieldChange{After: av}
+		}
+	}
+	return changes, nil
+}
+
+func jsonFields(v any) (map[string]any, error) {
+	fields := map[string]any{}
+	if v == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
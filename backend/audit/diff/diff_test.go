@@ -0,0 +1,32 @@
+package diff
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	type profile struct {
+		Name     string `json:"name"`
+		Phone    string `json:"phone"`
+		Password string `json:"-"`
+	}
+
+	changes, err := Compare(profile{Name: "Ann", Phone: "1", Password: "a"}, profile{Name: "Ann", Phone: "2", Password: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes["phone"].Before != "1" || changes["phone"].After != "2" {
+		t.Fatalf("unexpected diff %+v", changes)
+	}
+
+	created, err := Compare(nil, profile{Name: "Ben"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || created["name"].Before != nil || created["name"].After != "Ben" {
+		t.Fatalf("unexpected creation diff %+v", created)
+	}
+
+	deleted, _ := Compare(map[string]any{"flag": 0}, map[string]any{})
+	if _, ok := deleted["flag"]; !ok || deleted["flag"].After != nil {
+		t.Fatalf("unexpected deletion diff %+v", deleted)
+	}
+}
@@ -18,5 +18,5 @@ export GOOS=linux
 export GOARCH=amd64
 
 # 编译项目并将二进制文件输出到指定目录
-go build -o "$OUTPUT_DIR/$BINARY_NAME" main.go
+go build -tags prod -o "$OUTPUT_DIR/$BINARY_NAME" main.go
 echo "编译成功，二进制文件位于 $OUTPUT_DIR/$BINARY_NAME"
\ No newline at end of file
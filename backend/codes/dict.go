@@ -20,6 +20,8 @@ const (
 	CODE_ERR_REMOTE           = 16
 	CODE_ERR_REPEAT           = 17
 	CODE_ERR_OKX              = 18
+	CODE_ERR_FORBIDDEN        = 19
+	CODE_ERR_LOGIN_LOCKED     = 20
 
 	CODE_BOOKING_CONFLICT = 301
 
@@ -1,6 +1,8 @@
 package codes
 
 const (
-	TOPIC_TOKEN_SUB      = "topic:token:pub"
-	TOPIC_TOKEN_FLOW_SUB = "topic:token_flow:pub"
+	TOPIC_TOKEN_SUB       = "topic:token:pub"
+	TOPIC_TOKEN_FLOW_SUB  = "topic:token_flow:pub"
+	TOPIC_CHANNEL_CHANGED = "topic:channel:changed"
+	TOPIC_NOTIFY_PUSH     = "topic:notify:push"
 )
@@ -23,14 +23,23 @@ type HttpConfig struct {
 }
 
 type Config struct {
-	Database    DatabaseConfig `yaml:"database"`
-	Redis       RedisConfig    `yaml:"redis"`
-	Chain       []ChainConfig  `yaml:"chain"`
-	Log         LogConfig      `yaml:"log"`
-	AllStart    int            `yaml:"allStart"`
-	Cmd         CmdConfig      `yaml:"cmd"`
-	Http        HttpConfig     `yaml:"http"`
-	ProxyEnable bool           `yaml:"proxyEnable"`
+	Database       DatabaseConfig  `yaml:"database"`
+	Redis          RedisConfig     `yaml:"redis"`
+	Chain          []ChainConfig   `yaml:"chain"`
+	Log            LogConfig       `yaml:"log"`
+	AllStart       int             `yaml:"allStart"`
+	Cmd            CmdConfig       `yaml:"cmd"`
+	Http           HttpConfig      `yaml:"http"`
+	ProxyEnable    bool            `yaml:"proxyEnable"`
+	Auth           AuthConfig      `yaml:"auth"`
+	Mail           MailConfig      `yaml:"mail"`
+	Security       SecurityConfig  `yaml:"security"`
+	Profile        string          `yaml:"profile"`
+	DevAuth        DevAuthConfig   `yaml:"devAuth"`
+	Signature      SignatureConfig `yaml:"signature"`
+	OIDC           OIDCConfig      `yaml:"oidc"`
+	Storage        StorageConfig   `yaml:"storage"`
+	LessonTimezone string          `yaml:"lessonTimezone"` // IANA zone lesson dates and times are stored in, empty for the server zone
 }
 
 // DatabaseConfig holds the database connection parameters.
@@ -70,6 +79,137 @@ type LogConfig struct {
 	Path string `yaml:"path"`
 }
 
+// AuthConfig holds account verification and login policy switches.
+// RequireEmailVerified refuses sign-in to every account not marked active,
+// including ones created before verification existed, so it is only turned
+// on once those have been backfilled.
+type AuthConfig struct {
+	RequireEmailVerified bool             `yaml:"requireEmailVerified"`
+	VerifyTokenTTL       int              `yaml:"verifyTokenTTL"` // minutes
+	ResendInterval       int              `yaml:"resendInterval"` // seconds
+	WebBase              string           `yaml:"webBase"`        // frontend origin used in emailed links
+	ApiBase              string           `yaml:"apiBase"`        // public origin of this API, for links mail clients call directly
+	LoginGuard           LoginGuardConfig `yaml:"loginGuard"`
+	DeletionGraceDays    int              `yaml:"deletionGraceDays"`  // days before a deleted account is purged
+	GuardianConsentAge   int              `yaml:"guardianConsentAge"` // members younger than this need recorded guardian consent
+}
+
+// LoginGuardConfig sets when repeated login failures lock an account or IP.
+// Each failure past the threshold doubles the lockout, up to MaxLockout.
+type LoginGuardConfig struct {
+	AccountThreshold int `yaml:"accountThreshold"`
+	IPThreshold      int `yaml:"ipThreshold"`
+	Window           int `yaml:"window"`      // seconds a failure is remembered
+	BaseLockout      int `yaml:"baseLockout"` // seconds
+	MaxLockout       int `yaml:"maxLockout"`  // seconds
+}
+
+// OIDCConfig lists the social login providers. A provider without a client
+// id is left out.
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `yaml:"providers"`
+}
+
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"` // used in the /oauth/:provider routes
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	RedirectURL  string   `yaml:"redirectUrl"` // frontend page that receives code and state
+	Scopes       []string `yaml:"scopes"`
+
+	// providers that take a signed JWT as the client secret (Apple): the
+	// team and key IDs and the PEM private key, used instead of ClientSecret
+	TeamID     string `yaml:"teamId"`
+	KeyID      string `yaml:"keyId"`
+	PrivateKey string `yaml:"privateKey"`
+}
+
+// StorageConfig selects where uploaded files go: the local disk or an
+// S3-compatible bucket (AWS, MinIO, ...). MaxUploadSize is in bytes.
+type StorageConfig struct {
+	Backend       string             `yaml:"backend"` // local or s3
+	MaxUploadSize int64              `yaml:"maxUploadSize"`
+	Local         LocalStorageConfig `yaml:"local"`
+	S3            S3StorageConfig    `yaml:"s3"`
+}
+
+// LocalStorageConfig writes files under Dir and serves them at URLPath;
+// BaseURL is the public address of URLPath.
+type LocalStorageConfig struct {
+	Dir     string `yaml:"dir"`
+	URLPath string `yaml:"urlPath"`
+	BaseURL string `yaml:"baseUrl"`
+}
+
+// S3StorageConfig points at a bucket. LB_S3_ACCESS_KEY / LB_S3_SECRET_KEY
+// override the keys from the environment. PathStyle addresses the bucket as
+// endpoint/bucket, which MinIO needs.
+type S3StorageConfig struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+	PathStyle bool   `yaml:"pathStyle"`
+	BaseURL   string `yaml:"baseUrl"` // public URL of the bucket, e.g. a CDN
+}
+
+// MailConfig selects the outbound mail sink: smtp, file or memory.
+type MailConfig struct {
+	Driver   string `yaml:"driver"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	Dir      string `yaml:"dir"`
+}
+
+// SecurityConfig holds the key ring used to seal login and action tokens,
+// and the separate, never expiring, ring for sealed data at rest.
+// LB_TOKEN_KEYS / LB_TOKEN_ACTIVE_KEY and LB_DATA_KEYS / LB_DATA_ACTIVE_KEY
+// override them from the environment.
+type SecurityConfig struct {
+	ActiveKey     string           `yaml:"activeKey"`
+	TokenKeys     []TokenKeyConfig `yaml:"tokenKeys"`
+	ActiveDataKey string           `yaml:"activeDataKey"`
+	DataKeys      []TokenKeyConfig `yaml:"dataKeys"`
+}
+
+// TokenKeyConfig is one AES key of the ring. Secret is base64 of 16, 24 or
+// 32 bytes; the key stops being accepted after NotAfter (2006-01-02 or
+// RFC3339). Legacy marks the key used for tokens issued without a key id; it
+// must have a NotAfter and is refused outside the dev and local profiles.
+type TokenKeyConfig struct {
+	ID       string `yaml:"id"`
+	Secret   string `yaml:"secret"`
+	NotAfter string `yaml:"notAfter"`
+	Legacy   bool   `yaml:"legacy"`
+}
+
+// SignatureConfig bounds how old a signed request may be (MaxSkew, seconds)
+// and how long its REQUESTID is remembered to refuse replays (NonceTTL,
+// seconds, at least twice MaxSkew).
+type SignatureConfig struct {
+	MaxSkew  int `yaml:"maxSkew"`
+	NonceTTL int `yaml:"nonceTTL"`
+}
+
+// DevAuthConfig lets local and dev deployments impersonate test users by
+// sending "dev:<name>" as XAUTH. It is refused outside the dev/local profiles.
+type DevAuthConfig struct {
+	Enabled bool            `yaml:"enabled"`
+	Users   []DevUserConfig `yaml:"users"`
+}
+
+type DevUserConfig struct {
+	Name   string   `yaml:"name"`
+	UserID uint64   `yaml:"userId"`
+	UserNo string   `yaml:"userNo"`
+	Roles  []string `yaml:"roles"`
+}
+
 type RpcMapper struct {
 	Rpc   string
 	Quote int
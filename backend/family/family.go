@@ -0,0 +1,198 @@
+// Package family handles family members who get a login of their own: the
+// emailed invite, the guardian's booking grant and the consent records kept
+// for members under the consent age.
+package family
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/mailer"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/session"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/utils"
+	"gorm.io/gorm"
+)
+
+const INVITE_TTL = 7 * 24 * time.Hour
+
+var (
+	ErrInviteInvalid  = errors.New("member invite invalid or expired")
+	ErrNoEmail        = errors.New("member has no email address")
+	ErrAlreadyInvited = errors.New("member already has a login")
+)
+
+var ctx = context.Background()
+
+func inviteKey(memberID uint64) string {
+	return fmt.Sprintf("lb:member:invite:%d", memberID)
+}
+
+func digest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ConsentAge is the age below which the guardian's consent is recorded.
+func ConsentAge() int {
+	return config.GetConfig().Auth.GuardianConsentAge
+}
+
+// ConsentRequired reports whether the member is under the consent age. A
+// missing or unreadable birthday counts as under age.
+func ConsentRequired(member model.UserMember) bool {
+	limit := ConsentAge()
+	if limit <= 0 {
+		return false
+	}
+	age, ok := utils.Age(member.Birthday, time.Now())
+	return !ok || age < limit
+}
+
+func RecordConsent(guardianID, memberID uint64, purpose string, granted bool, ip string) error {
+	return system.GetDb().Create(&model.GuardianConsent{
+		GuardianID: guardianID,
+		MemberID:   memberID,
+		Purpose:    purpose,
+		Granted:    granted,
+		IP:         ip,
+		AddTime:    time.Now(),
+	}).Error
+}
+
+// Invite mails the member a link to set up their own login. Inviting again
+// replaces the previous link.
+func Invite(guardian model.UserInfo, member model.UserMember) error {
+	if len(member.Email) == 0 {
+		return ErrNoEmail
+	}
+	if member.LoginUserID > 0 {
+		return ErrAlreadyInvited
+	}
+	token, err := security.IssueActionToken(security.ACTION_MEMBER_INVITE, member.ID, INVITE_TTL)
+	if err != nil {
+		return err
+	}
+	if err := system.GetRedis().Set(ctx, inviteKey(member.ID), digest(token), INVITE_TTL).Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	system.GetDb().Model(&model.UserMember{}).Where("id = ?", member.ID).Update("invite_time", now)
+
+	link := fmt.Sprintf("%s/member-invite?token=%s", strings.TrimRight(config.GetConfig().Auth.WebBase, "/"), url.QueryEscape(token))
+	return mailer.Send(mailer.Message{
+		To:      member.Email,
+		Subject: fmt.Sprintf("%s invited you to LangBridge", guardian.Name),
+		Body: fmt.Sprintf("Hi %s,\n\n%s has set up a LangBridge login for you, so you can see your own lessons. Open the link below to choose your password:\n\n%s\n\nThe link expires in %s.\n",
+			member.Name, guardian.Name, link, INVITE_TTL),
+	})
+}
+
+// CancelInvite makes any outstanding invite link unusable, e.g. after the
+// member's email address changed.
+func CancelInvite(memberID uint64) {
+	system.GetRedis().Del(ctx, inviteKey(memberID))
+}
+
+// Invited checks an invite token and returns the member it was sent to,
+// without using it up.
+func Invited(token string) (model.UserMember, error) {
+	return checkInvite(token, false)
+}
+
+// Redeem is Invited, but the token is used up; call it only when the login
+// is about to be created.
+func Redeem(token string) (model.UserMember, error) {
+	return checkInvite(token, true)
+}
+
+func checkInvite(token string, redeem bool) (model.UserMember, error) {
+	var member model.UserMember
+	memberID, err := security.ParseActionToken(security.ACTION_MEMBER_INVITE, token)
+	if err != nil {
+		return member, ErrInviteInvalid
+	}
+	var stored string
+	if redeem {
+		stored, err = system.GetRedis().GetDel(ctx, inviteKey(memberID)).Result()
+	} else {
+		stored, err = system.GetRedis().Get(ctx, inviteKey(memberID)).Result()
+	}
+	if err != nil || stored != digest(token) {
+		return member, ErrInviteInvalid
+	}
+	system.GetDb().Model(&model.UserMember{}).Where("id = ? and flag != ?", memberID, -1).First(&member)
+	if member.ID == 0 || len(member.Email) == 0 {
+		return member, ErrInviteInvalid
+	}
+	if member.LoginUserID > 0 {
+		return member, ErrAlreadyInvited
+	}
+	return member, nil
+}
+
+// Link attaches a new login to the member, as the member role and with
+// booking rights if the guardian already allowed them.
+func Link(db *gorm.DB, member model.UserMember, userID uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.UserMember{}).Where("id = ?", member.ID).
+			Updates(map[string]interface{}{"login_user_id": userID, "update_time": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		if !member.CanBook {
+			return nil
+		}
+		return tx.Save(&model.UserPermission{UserID: userID, Permission: security.PERM_COURSE_BOOK, AddTime: time.Now()}).Error
+	})
+}
+
+// SetBooking lets the member's login book lessons, or stops it. Revoking
+// signs the member out so the change applies at once.
+func SetBooking(member model.UserMember, allow bool) error {
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.UserMember{}).Where("id = ?", member.ID).
+			Updates(map[string]interface{}{"can_book": allow, "update_time": time.Now()}).Error
+		if err != nil || member.LoginUserID == 0 {
+			return err
+		}
+		if !allow {
+			return tx.Model(&model.UserPermission{}).
+				Where("user_id = ? and permission = ? and flag != ?", member.LoginUserID, security.PERM_COURSE_BOOK, -1).
+				Update("flag", -1).Error
+		}
+		var count int64
+		err = tx.Model(&model.UserPermission{}).
+			Where("user_id = ? and permission = ? and flag != ?", member.LoginUserID, security.PERM_COURSE_BOOK, -1).
+			Count(&count).Error
+		if err != nil || count > 0 {
+			return err
+		}
+		return tx.Save(&model.UserPermission{UserID: member.LoginUserID, Permission: security.PERM_COURSE_BOOK, AddTime: time.Now()}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if !allow && member.LoginUserID > 0 {
+		return session.RevokeAll(member.LoginUserID)
+	}
+	return nil
+}
+
+// Pinned returns the member a login belongs to, if it is a member's own
+// login. Such a caller sees and books only under that member, on the
+// guardian's account.
+func Pinned(userID uint64) (model.UserMember, bool) {
+	var member model.UserMember
+	system.GetDb().Model(&model.UserMember{}).Where("login_user_id = ? and flag != ?", userID, -1).First(&member)
+	return member, member.ID > 0
+}
@@ -0,0 +1,162 @@
+// Package imaging checks uploaded pictures and turns them into square
+// renditions. Every rendition is re-encoded from the decoded pixels, so EXIF
+// and any other metadata in the upload never reach storage.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MAX_DIMENSION and MAX_PIXELS bound the image before decoding, so a
+	// small file cannot expand into a huge bitmap; at MAX_PIXELS one RGBA
+	// copy is 100 MB.
+	MAX_DIMENSION = 8000
+	MAX_PIXELS    = 25_000_000
+	JPEG_QUALITY  = 85
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image type")
+	ErrDimensions  = errors.New("image dimensions out of range")
+)
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Size is one rendition to produce: a square of Pixels on a side.
+type Size struct {
+	Name   string
+	Pixels int
+}
+
+type Rendition struct {
+	Name        string
+	Pixels      int
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// DetectType sniffs the content type from the data itself, never from the
+// file name or the client's header.
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return contentType, fmt.Errorf("%w: %s", ErrUnsupported, contentType)
+	}
+	return contentType, nil
+}
+
+// Square decodes data, applies the JPEG orientation, crops the centre square
+// and scales it down to each size. Images are never scaled up. JPEG uploads
+// come out as JPEG; PNG and GIF as PNG, to keep transparency.
+func Square(data []byte, sizes []Size) ([]Rendition, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if conf.Width <= 0 || conf.Height <= 0 || conf.Width > MAX_DIMENSION || conf.Height > MAX_DIMENSION ||
+		conf.Width*conf.Height > MAX_PIXELS {
+		return nil, ErrDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	src := toNRGBA(img)
+	if contentType == "image/jpeg" {
+		src = orient(src, jpegOrientation(data))
+	}
+	src = cropSquare(src)
+
+	renditions := make([]Rendition, 0, len(sizes))
+	for _, size := range sizes {
+		pixels := min(size.Pixels, src.Bounds().Dx())
+		scaled := scale(src, pixels)
+
+		var buf bytes.Buffer
+		r := Rendition{Name: size.Name, Pixels: pixels}
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: JPEG_QUALITY})
+			r.ContentType, r.Ext = "image/jpeg", "jpg"
+		} else {
+			err = png.Encode(&buf, scaled)
+			r.ContentType, r.Ext = "image/png", "png"
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Data = buf.Bytes()
+		renditions = append(renditions, r)
+	}
+	return renditions, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+func cropSquare(img *image.NRGBA) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	side := min(w, h)
+	x0, y0 := (w-side)/2, (h-side)/2
+	return toNRGBA(img.SubImage(image.Rect(x0, y0, x0+side, y0+side)))
+}
+
+// scale shrinks a square image to pixels x pixels by averaging the source
+// pixels that fall into each target pixel.
+func scale(src *image.NRGBA, pixels int) *image.NRGBA {
+	side := src.Bounds().Dx()
+	if pixels == side {
+		return src
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, pixels, pixels))
+	for y := 0; y < pixels; y++ {
+		sy0, sy1 := y*side/pixels, max((y+1)*side/pixels, y*side/pixels+1)
+		for x := 0; x < pixels; x++ {
+			sx0, sx1 := x*side/pixels, max((x+1)*side/pixels, x*side/pixels+1)
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					// weight colour by alpha so transparent pixels do not darken edges
+					alpha := uint64(p[3])
+					r += uint64(p[0]) * alpha
+					g += uint64(p[1]) * alpha
+					b += uint64(p[2]) * alpha
+					a += alpha
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+			}
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
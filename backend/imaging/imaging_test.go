@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var sizes = []Size{{"main", 64}, {"thumb", 16}}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an APP1 EXIF segment carrying only the
+// orientation tag right after the JPEG SOI marker.
+func withOrientation(jpg []byte, o uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, o)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestSquareCropsAndScales(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	out, err := Square(encodePNG(t, img), sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range out {
+		if r.ContentType != "image/png" || r.Pixels != sizes[i].Pixels {
+			t.Fatalf("unexpected rendition %s: %s %d", r.Name, r.ContentType, r.Pixels)
+		}
+		got, _, err := image.Decode(bytes.NewReader(r.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := got.Bounds(); b.Dx() != r.Pixels || b.Dy() != r.Pixels {
+			t.Fatalf("%s is %v", r.Name, b)
+		}
+	}
+}
+
+func TestSquareDoesNotUpscale(t *testing.T) {
+	out, err := Square(encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 10, 30))), sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0].Pixels != 10 || out[1].Pixels != 10 {
+		t.Fatalf("expected 10px renditions, got %d and %d", out[0].Pixels, out[1].Pixels)
+	}
+}
+
+func TestSquareStripsExifAndRotates(t *testing.T) {
+	// left half black, right half white; orientation 6 means the stored
+	// picture has to be turned 90 degrees clockwise to be upright
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 16; x < 32; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	data := withOrientation(buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("orientation not read: %d", jpegOrientation(data))
+	}
+
+	out, err := Square(data, []Size{{"main", 32}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out[0].Data, []byte("Exif")) || jpegOrientation(out[0].Data) != 1 {
+		t.Fatal("EXIF survived re-encoding")
+	}
+	got, _, _ := image.Decode(bytes.NewReader(out[0].Data))
+	top, _, _, _ := got.At(16, 2).RGBA()
+	bottom, _, _, _ := got.At(16, 29).RGBA()
+	if top > 0x4000 || bottom < 0xC000 {
+		t.Fatalf("image not rotated: top %x bottom %x", top, bottom)
+	}
+}
+
+func TestSquareRejects(t *testing.T) {
+	if _, err := Square([]byte("<svg xmlns='http://www.w3.org/2000/svg'/>"), sizes); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("svg accepted: %v", err)
+	}
+	if _, err := Square([]byte("\x89PNG\r\n\x1a\ngarbage"), sizes); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("corrupt png accepted: %v", err)
+	}
+	huge := encodePNG(t, image.NewGray(image.Rect(0, 0, MAX_DIMENSION+1, 1)))
+	if _, err := Square(huge, sizes); !errors.Is(err, ErrDimensions) {
+		t.Fatalf("oversized image accepted: %v", err)
+	}
+	wide := encodePNG(t, image.NewGray(image.Rect(0, 0, MAX_DIMENSION, MAX_PIXELS/MAX_DIMENSION+1)))
+	if _, err := Square(wide, sizes); !errors.Is(err, ErrDimensions) {
+		t.Fatalf("image over the pixel budget accepted: %v", err)
+	}
+}
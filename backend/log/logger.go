@@ -141,6 +141,14 @@ func Infof(format string, args ...interface{}) {
 	logger.Infof(format, args...)
 }
 
+func Warn(args ...interface{}) {
+	logger.Warn(args...)
+}
+
+func Warnf(format string, args ...interface{}) {
+	logger.Warnf(format, args...)
+}
+
 func Error(args ...interface{}) {
 	logger.Error(args...)
 }
@@ -0,0 +1,122 @@
+// Package loginguard counts failed logins per account name and per client IP
+// in Redis and locks either one out with exponential backoff.
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+)
+
+const (
+	SCOPE_ACCOUNT = "account"
+	SCOPE_IP      = "ip"
+)
+
+var ctx = context.Background()
+
+func failKey(scope, subject string) string {
+	return fmt.Sprintf("lb:login:fail:%s:%s", scope, subject)
+}
+
+func lockKey(scope, subject string) string {
+	return fmt.Sprintf("lb:login:lock:%s:%s", scope, subject)
+}
+
+// Account normalises a login name so "Foo@x.com " and "foo@x.com" share a
+// counter. Names are counted whether or not an account exists.
+func Account(loginName string) string {
+	return strings.ToLower(strings.TrimSpace(loginName))
+}
+
+// Locked returns how much longer logins for the account or from the IP are
+// refused, 0 if they are not.
+func Locked(account, ip string) time.Duration {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return 0
+	}
+	var remaining time.Duration
+	for _, key := range []string{lockKey(SCOPE_ACCOUNT, account), lockKey(SCOPE_IP, ip)} {
+		ttl, err := rdb.PTTL(ctx, key).Result()
+		if err != nil {
+			log.Error("[LoginGuard] lock lookup error: ", key, err)
+			continue
+		}
+		remaining = max(remaining, ttl)
+	}
+	return remaining
+}
+
+// Fail records a failed login and returns the lockout it caused, if any.
+func Fail(account, ip string, userID uint64) time.Duration {
+	conf := config.GetConfig().Auth.LoginGuard
+	accountLock := fail(SCOPE_ACCOUNT, account, conf.AccountThreshold, userID, ip)
+	ipLock := fail(SCOPE_IP, ip, conf.IPThreshold, 0, ip)
+	return max(accountLock, ipLock)
+}
+
+// Succeed resets the account counter. The IP counter is left alone, so one
+// valid account does not let a client keep guessing others.
+func Succeed(account string) {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return
+	}
+	rdb.Del(ctx, failKey(SCOPE_ACCOUNT, account))
+}
+
+// Clear lifts a lockout and forgets its failures.
+func Clear(scope, subject string) error {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return fmt.Errorf("redis unavailable")
+	}
+	return rdb.Del(ctx, failKey(scope, subject), lockKey(scope, subject)).Err()
+}
+
+func fail(scope, subject string, threshold int, userID uint64, ip string) time.Duration {
+	rdb := system.GetRedis()
+	if rdb == nil || len(subject) == 0 {
+		return 0
+	}
+	conf := config.GetConfig().Auth.LoginGuard
+	window := time.Duration(conf.Window) * time.Second
+
+	key := failKey(scope, subject)
+	failures, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		log.Error("[LoginGuard] count failure error: ", key, err)
+		return 0
+	}
+	lock := security.LockoutDuration(int(failures), threshold,
+		time.Duration(conf.BaseLockout)*time.Second, time.Duration(conf.MaxLockout)*time.Second)
+	// keep the count at least as long as the lockout so the next one escalates
+	rdb.Expire(ctx, key, max(window, 2*lock))
+	if lock == 0 {
+		return 0
+	}
+
+	rdb.Set(ctx, lockKey(scope, subject), failures, lock)
+	log.Warnf("[LoginGuard] %s %q locked for %v after %d failures", scope, subject, lock, failures)
+	event := model.LoginLockout{
+		Scope:       scope,
+		Subject:     subject,
+		UserID:      userID,
+		IP:          ip,
+		Failures:    int(failures),
+		LockedUntil: time.Now().Add(lock),
+		AddTime:     time.Now(),
+	}
+	if err := system.GetDb().Create(&event).Error; err != nil {
+		log.Error("[LoginGuard] record lockout error: ", err)
+	}
+	return lock
+}
@@ -0,0 +1,164 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	Headers map[string]string // extra headers, e.g. List-Unsubscribe
+}
+
+// Mailer delivers a single message. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	defaultMailer Mailer
+	defaultOnce   sync.Once
+	defaultLock   sync.RWMutex
+)
+
+// Default returns the mailer selected by the mail section of the config.
+func Default() Mailer {
+	defaultOnce.Do(func() {
+		m := New(config.GetConfig().Mail)
+		defaultLock.Lock()
+		if defaultMailer == nil {
+			defaultMailer = m
+		}
+		defaultLock.Unlock()
+	})
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultMailer
+}
+
+// SetDefault replaces the process wide mailer, mostly for tests.
+func SetDefault(m Mailer) {
+	defaultOnce.Do(func() {})
+	defaultLock.Lock()
+	defaultMailer = m
+	defaultLock.Unlock()
+}
+
+func Send(msg Message) error {
+	return Default().Send(msg)
+}
+
+func New(conf config.MailConfig) Mailer {
+	switch conf.Driver {
+	case "smtp":
+		return &SMTPMailer{conf: conf}
+	case "file":
+		dir := conf.Dir
+		if len(dir) == 0 {
+			dir = filepath.Join(os.TempDir(), "langbridge-mail")
+		}
+		return &FileMailer{Dir: dir, From: conf.From}
+	case "memory", "":
+		return &MemoryMailer{}
+	default:
+		log.Error("[Mail] unknown driver, falling back to memory: ", conf.Driver)
+		return &MemoryMailer{}
+	}
+}
+
+type SMTPMailer struct {
+	conf config.MailConfig
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	addr := fmt.Sprintf("%s:%d", m.conf.Host, m.conf.Port)
+	var auth smtp.Auth
+	if len(m.conf.Username) > 0 {
+		auth = smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+	}
+	return smtp.SendMail(addr, auth, envelopeAddress(m.conf.From), []string{msg.To}, format(m.conf.From, msg))
+}
+
+// FileMailer writes every message as an .eml file, handy for local runs.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0644)
+}
+
+// MemoryMailer keeps sent messages in memory for tests.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, msg)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	m.sent = nil
+	m.mu.Unlock()
+}
+
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// a value must not smuggle in headers of its own
+		value := strings.NewReplacer("\r", "", "\n", "").Replace(msg.Headers[name])
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+	return []byte(b.String())
+}
+
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/langbridge/backend/config"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := New(config.MailConfig{Driver: "file", Dir: dir, From: "LangBridge <no-reply@langbridge.local>"})
+
+	err := m.Send(Message{To: "parent@example.com", Subject: "hello", Body: "body text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one mail file, got %d", len(files))
+	}
+	raw, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if !strings.Contains(string(raw), "To: parent@example.com") || !strings.HasSuffix(string(raw), "body text") {
+		t.Fatalf("unexpected mail content: %s", raw)
+	}
+}
+
+func TestFileMailerHeaders(t *testing.T) {
+	dir := t.TempDir()
+	m := New(config.MailConfig{Driver: "file", Dir: dir, From: "LangBridge <no-reply@langbridge.local>"})
+
+	err := m.Send(Message{To: "parent@example.com", Subject: "hello", Body: "body text",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/u>\r\nBcc: x@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	raw, _ := os.ReadFile(dir + "/" + files[0].Name())
+	if !strings.Contains(string(raw), "List-Unsubscribe: <https://example.com/u>Bcc: x@example.com\r\n") {
+		t.Fatalf("extra header missing or not sanitised: %s", raw)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+	SetDefault(m)
+
+	Send(Message{To: "a@example.com", Subject: "s"})
+	if msgs := m.Messages(); len(msgs) != 1 || msgs[0].To != "a@example.com" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+	if envelopeAddress("LangBridge <no-reply@langbridge.local>") != "no-reply@langbridge.local" {
+		t.Fatal("envelope address not extracted")
+	}
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"github.com/joho/godotenv"
+	"github.com/langbridge/backend/account"
 	router "github.com/langbridge/backend/api"
+	"github.com/langbridge/backend/api/interceptor"
 	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/notify"
+	"github.com/langbridge/backend/security"
 )
 
 func main() {
@@ -11,7 +15,21 @@ func main() {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
+	if _, err := security.DefaultKeyRing(); err != nil {
+		log.Fatal("Error loading token key ring: ", err)
+	}
+	if _, err := security.DefaultDataKeyRing(); err != nil {
+		log.Fatal("Error loading data key ring: ", err)
+	}
+	if err := security.CheckDevAuth(); err != nil {
+		log.Fatal(err)
+	}
 	//topic.StartSubscription()
+	account.StartPurgeLoop()
+	go interceptor.WatchChannels()
+	go notify.WatchPush()
+	notify.StartDeferredLoop()
+	notify.StartReminderLoop()
 
 	router.Init()
 }
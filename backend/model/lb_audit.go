@@ -0,0 +1,24 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent is one entry of the security audit log. Rows are only ever
+// inserted; nothing updates or deletes them.
+type AuditEvent struct {
+	ID        uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID   uint64          `gorm:"column:actor_id;index" json:"actor_id"` // 0 when the caller is not signed in
+	UserID    uint64          `gorm:"column:user_id;index" json:"user_id"`   // the account acted on, 0 if unknown
+	Action    string          `gorm:"column:action;size:32;index" json:"action"`
+	IP        string          `gorm:"column:ip;size:64" json:"ip"`
+	AppID     string          `gorm:"column:app_id;size:64" json:"app_id"`
+	RequestID string          `gorm:"column:request_id;size:128" json:"request_id"`
+	Diff      json.RawMessage `gorm:"column:diff;type:text" json:"diff"` // field -> {before, after}
+	AddTime   time.Time       `gorm:"column:add_time;index" json:"add_time"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_event"
+}
@@ -30,9 +30,11 @@ func (CourseInfo) TableName() string {
 
 type Teacher struct {
 	ID                uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID            uint64    `gorm:"column:user_id" json:"-"` // login account, 0 if the teacher cannot sign in
 	Name              string    `gorm:"column:name" json:"name"`
 	Introduction      string    `gorm:"column:introduction" json:"introduction"`
 	Detail            string    `gorm:"column:detail" json:"detail"`
+	Photo             string    `gorm:"column:photo" json:"photo"`
 	FirstLanguage     string    `gorm:"column:first_language" json:"first_language"`
 	NationalityID     uint64    `gorm:"column:nationality_id" json:"nationality_id"`
 	NationalityName   string    `gorm:"column:nationality_name" json:"nationality_name"`
@@ -53,6 +55,7 @@ func (Teacher) TableName() string {
 type UserCourse struct {
 	ID       uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID   uint64    `gorm:"column:user_id" json:"user_id"`
+	MemberID uint64    `gorm:"column:member_id" json:"member_id"` // the learner, 0 for the account holder
 	CourseID uint64    `gorm:"column:course_id" json:"course_id"`
 	AddTime  time.Time `gorm:"column:add_time" json:"add_time"`
 	Status   string    `gorm:"column:status" json:"status"`
@@ -67,6 +70,7 @@ type UserCourseWithCourse struct {
 	// user_course 表字段
 	UserCourseID uint64    `gorm:"column:uc_id" json:"user_course_id"`
 	UserID       uint64    `gorm:"column:user_id" json:"user_id"`
+	MemberID     uint64    `gorm:"column:member_id" json:"member_id"`
 	CourseID     uint64    `gorm:"column:course_id" json:"course_id"`
 	UCStatus     string    `gorm:"column:uc_status" json:"user_course_status"`
 	UCAddTime    time.Time `gorm:"column:uc_add_time" json:"user_course_add_time"`
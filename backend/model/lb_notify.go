@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+const (
+	NOTIFY_EVENT_BOOKING_CONFIRMED = "booking_confirmed"
+	NOTIFY_EVENT_LESSON_REMINDER   = "lesson_reminder"
+	NOTIFY_EVENT_PAYMENT_RECEIPT   = "payment_receipt"
+
+	NOTIFY_CHANNEL_EMAIL = "email"
+	NOTIFY_CHANNEL_SMS   = "sms"
+	NOTIFY_CHANNEL_INAPP = "inapp"
+	NOTIFY_CHANNEL_WS    = "ws"
+)
+
+// NotifyPreference overrides the default for one event on one channel.
+// Without a row the defaults in package notify apply.
+type NotifyPreference struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID     uint64    `gorm:"column:user_id;uniqueIndex:uk_notify_pref" json:"-"`
+	Event      string    `gorm:"column:event;size:32;uniqueIndex:uk_notify_pref" json:"event"`
+	Channel    string    `gorm:"column:channel;size:16;uniqueIndex:uk_notify_pref" json:"channel"`
+	Enabled    bool      `gorm:"column:enabled" json:"enabled"`
+	UpdateTime time.Time `gorm:"column:update_time" json:"-"`
+}
+
+func (NotifyPreference) TableName() string {
+	return "notify_preference"
+}
+
+// NotifySetting holds the per-user options that are not per event. Quiet
+// hours are "HH:mm" wall clock times in the user's timezone; empty means
+// none.
+type NotifySetting struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID     uint64    `gorm:"column:user_id;uniqueIndex" json:"-"`
+	QuietStart string    `gorm:"column:quiet_start;size:5" json:"quiet_start"`
+	QuietEnd   string    `gorm:"column:quiet_end;size:5" json:"quiet_end"`
+	UpdateTime time.Time `gorm:"column:update_time" json:"-"`
+}
+
+func (NotifySetting) TableName() string {
+	return "notify_setting"
+}
+
+// Notification is an in-app notification, shown in the user's inbox.
+type Notification struct {
+	ID       uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   uint64     `gorm:"column:user_id;index" json:"-"`
+	Event    string     `gorm:"column:event;size:32" json:"event"`
+	Title    string     `gorm:"column:title" json:"title"`
+	Body     string     `gorm:"column:body;type:text" json:"body"`
+	Link     string     `gorm:"column:link" json:"link"`
+	ReadTime *time.Time `gorm:"column:read_time" json:"read_time"`
+	AddTime  time.Time  `gorm:"column:add_time;index" json:"add_time"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
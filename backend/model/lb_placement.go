@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+const (
+	PLACEMENT_STATUS_ACTIVE    = "active"
+	PLACEMENT_STATUS_FINISHED  = "finished"
+	PLACEMENT_STATUS_ABANDONED = "abandoned"
+)
+
+// PlacementItem is one question in the placement item bank. Level is the
+// CEFR band as in CourseInfo.Level (1 A1 to 6 C2).
+type PlacementItem struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Language   string    `gorm:"column:language;size:16;index:idx_placement_item" json:"language"`
+	Skill      string    `gorm:"column:skill;size:16;index:idx_placement_item" json:"skill"`
+	Level      int       `gorm:"column:level;index:idx_placement_item" json:"level"`
+	Prompt     string    `gorm:"column:prompt;type:text" json:"prompt"`
+	Media      string    `gorm:"column:media" json:"media"`               // audio or image URL, listening items
+	Options    string    `gorm:"column:options;type:text" json:"options"` // JSON array of choices
+	Answer     int       `gorm:"column:answer" json:"answer"`             // index into Options
+	UpdateTime time.Time `gorm:"column:update_time" json:"update_time"`
+	AddTime    time.Time `gorm:"column:add_time" json:"add_time"`
+	Flag       int       `gorm:"column:flag" json:"flag"`
+}
+
+func (PlacementItem) TableName() string {
+	return "placement_item"
+}
+
+// PlacementSession is one sitting of the placement test by a learner: the
+// account holder (MemberID 0) or one of their members.
+type PlacementSession struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64    `gorm:"column:user_id;index" json:"-"`
+	MemberID   uint64    `gorm:"column:member_id" json:"member_id"`
+	Language   string    `gorm:"column:language;size:16" json:"language"`
+	Status     string    `gorm:"column:status;size:16" json:"status"`
+	ItemID     uint64    `gorm:"column:item_id" json:"-"`           // the question waiting for an answer
+	Answers    string    `gorm:"column:answers;type:text" json:"-"` // JSON []placement.Answer
+	Result     string    `gorm:"column:result;type:text" json:"-"`  // JSON placement.Result once finished
+	Level      int       `gorm:"column:level" json:"level"`
+	UpdateTime time.Time `gorm:"column:update_time" json:"update_time"`
+	AddTime    time.Time `gorm:"column:add_time" json:"add_time"`
+}
+
+func (PlacementSession) TableName() string {
+	return "placement_session"
+}
+
+// LearnerLevel is a learner's CEFR level in one language, from their latest
+// placement test in it. Learners without a row are not placed there.
+type LearnerLevel struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64    `gorm:"column:user_id;uniqueIndex:idx_learner_level" json:"-"`
+	MemberID   uint64    `gorm:"column:member_id;uniqueIndex:idx_learner_level" json:"member_id"`
+	Language   string    `gorm:"column:language;size:16;uniqueIndex:idx_learner_level" json:"language"`
+	Level      int       `gorm:"column:level" json:"level"`
+	SessionID  uint64    `gorm:"column:session_id" json:"session_id"` // the placement session it came from
+	UpdateTime time.Time `gorm:"column:update_time" json:"update_time"`
+	AddTime    time.Time `gorm:"column:add_time" json:"add_time"`
+}
+
+func (LearnerLevel) TableName() string {
+	return "learner_level"
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// LearningGoal is a target a learner (the account holder, MemberID 0, or
+// one of their members) works towards in a language. Levels are as in
+// CourseInfo.Level; Exam, when set, is the Cambridge exam that certifies
+// TargetLevel.
+type LearningGoal struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64    `gorm:"column:user_id;index" json:"-"`
+	MemberID    uint64    `gorm:"column:member_id" json:"member_id"`
+	Language    string    `gorm:"column:language;size:16" json:"language"`
+	StartLevel  int       `gorm:"column:start_level" json:"start_level"` // the learner's level when the goal was set
+	TargetLevel int       `gorm:"column:target_level" json:"target_level"`
+	Exam        string    `gorm:"column:exam;size:8" json:"exam"`
+	TargetDate  string    `gorm:"column:target_date;size:10" json:"target_date"` // yyyy-MM-dd, empty for none
+	UpdateTime  time.Time `gorm:"column:update_time" json:"update_time"`
+	AddTime     time.Time `gorm:"column:add_time" json:"add_time"`
+	Flag        int       `gorm:"column:flag" json:"flag"`
+}
+
+func (LearningGoal) TableName() string {
+	return "learning_goal"
+}
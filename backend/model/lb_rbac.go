@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// UserRole grants one role to a user; a user may hold several.
+type UserRole struct {
+	ID      uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID  uint64    `gorm:"column:user_id;index" json:"user_id"`
+	Role    string    `gorm:"column:role;size:32" json:"role"`
+	AddTime time.Time `gorm:"column:add_time" json:"add_time"`
+	Flag    int       `gorm:"column:flag" json:"flag"`
+}
+
+func (UserRole) TableName() string {
+	return "user_role"
+}
+
+// UserPermission grants a single permission on top of the user's roles.
+type UserPermission struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint64    `gorm:"column:user_id;index" json:"user_id"`
+	Permission string    `gorm:"column:permission;size:64" json:"permission"`
+	AddTime    time.Time `gorm:"column:add_time" json:"add_time"`
+	Flag       int       `gorm:"column:flag" json:"flag"`
+}
+
+func (UserPermission) TableName() string {
+	return "user_permission"
+}
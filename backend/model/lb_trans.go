@@ -8,6 +8,7 @@ type CourseBookTrans struct {
 	TeacherID  uint64    `gorm:"column:teacher_id" json:"teacher_id"`
 	CourseID   uint64    `gorm:"column:course_id" json:"course_id"`
 	UserID     uint64    `gorm:"column:user_id" json:"user_id"`
+	MemberID   uint64    `gorm:"column:member_id" json:"member_id"` // the learner, 0 for the account holder
 	LessonDate time.Time `gorm:"column:lesson_date" json:"lesson_date"`
 	StartTime  string    `gorm:"column:start_time" json:"start_time"`
 	EndTime    string    `gorm:"column:end_time" json:"end_time"`
@@ -24,4 +25,9 @@ type CourseBookWithJoin struct {
 	CourseBookTrans
 	TeacherName string `json:"teacher_name"`
 	CourseName  string `json:"course_name"`
+	MemberName  string `json:"member_name"`
+	// the lesson as instants in the viewer's zone, filled when rendering
+	StartAt  time.Time `gorm:"-" json:"start_at"`
+	EndAt    time.Time `gorm:"-" json:"end_at"`
+	Timezone string    `gorm:"-" json:"timezone"`
 }
@@ -2,6 +2,12 @@ package model
 
 import "time"
 
+const (
+	USER_STATUS_UNVERIFIED = "00" // waiting for email verification
+	USER_STATUS_ACTIVE     = "20"
+	USER_STATUS_DELETED    = "99" // personal data purged
+)
+
 type UserInfo struct {
 	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
 	LoginId    string    `gorm:"column:login_id;type:varchar(255);not null" json:"login_id"`
@@ -30,6 +36,8 @@ type UserProfile struct {
 	LivingCountryCode string    `gorm:"column:living_country_code" json:"living_country_code"`
 	ContactPhone      string    `gorm:"column:contact_phone" json:"contact_phone"`
 	NativeLanguage    string    `gorm:"column:native_language" json:"native_language"`
+	Timezone          string    `gorm:"column:timezone" json:"timezone"` // IANA zone, e.g. Europe/London
+	Locale            string    `gorm:"column:locale" json:"locale"`     // BCP 47 tag, e.g. en-GB
 	UpdateTime        time.Time `gorm:"column:update_time" json:"update_time"`
 }
 
@@ -51,8 +59,102 @@ type UserMember struct {
 	Birthday    string    `gorm:"column:birthday" json:"birthday"`
 	Personality string    `gorm:"column:personality" json:"personality"`
 	Character   string    `gorm:"column:character" json:"character"`
+	LoginUserID uint64    `gorm:"column:login_user_id;index" json:"login_user_id"` // the member's own login, 0 if not invited yet
+	CanBook     bool      `gorm:"column:can_book" json:"can_book"`                 // the guardian lets the member book lessons
+	InviteTime  time.Time `gorm:"column:invite_time" json:"invite_time"`
 }
 
 func (UserMember) TableName() string {
 	return "user_member"
 }
+
+// LoginLockout records each time repeated login failures locked an account
+// name or a client IP.
+type LoginLockout struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope       string    `gorm:"column:scope;size:16" json:"scope"` // account or ip
+	Subject     string    `gorm:"column:subject;size:255" json:"subject"`
+	UserID      uint64    `gorm:"column:user_id" json:"user_id"` // 0 when the name matches no account
+	IP          string    `gorm:"column:ip;size:64" json:"ip"`
+	Failures    int       `gorm:"column:failures" json:"failures"`
+	LockedUntil time.Time `gorm:"column:locked_until" json:"locked_until"`
+	AddTime     time.Time `gorm:"column:add_time" json:"add_time"`
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockout"
+}
+
+// UserTwoFactor holds a user's TOTP enrollment. Secret is sealed with the
+// token key ring; BackupCodes is a JSON list of unused backup code digests.
+type UserTwoFactor struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64    `gorm:"column:user_id;uniqueIndex" json:"user_id"`
+	Secret      string    `gorm:"column:secret;size:255" json:"-"`
+	Enabled     bool      `gorm:"column:enabled" json:"enabled"`
+	BackupCodes string    `gorm:"column:backup_codes;type:text" json:"-"`
+	LastStep    int64     `gorm:"column:last_step" json:"-"` // last TOTP step used, against replays
+	AddTime     time.Time `gorm:"column:add_time" json:"add_time"`
+	UpdateTime  time.Time `gorm:"column:update_time" json:"update_time"`
+}
+
+func (UserTwoFactor) TableName() string {
+	return "user_two_factor"
+}
+
+// UserIdentity links an external OIDC account (provider + subject) to a user.
+type UserIdentity struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint64    `gorm:"column:user_id;index" json:"user_id"`
+	Provider  string    `gorm:"column:provider;size:32;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject   string    `gorm:"column:subject;size:255;uniqueIndex:idx_provider_subject" json:"-"`
+	Email     string    `gorm:"column:email" json:"email"`
+	AddTime   time.Time `gorm:"column:add_time" json:"add_time"`
+	LastLogin time.Time `gorm:"column:last_login" json:"last_login"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identity"
+}
+
+const (
+	DELETION_PENDING   = "pending"
+	DELETION_CANCELLED = "cancelled"
+	DELETION_DONE      = "done"
+)
+
+// AccountDeletion is a user's request to erase their account. The data is
+// purged once PurgeAfter has passed, unless the request is cancelled first.
+type AccountDeletion struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      uint64    `gorm:"column:user_id;index" json:"user_id"`
+	Status      string    `gorm:"column:status;size:16" json:"status"`
+	RequestTime time.Time `gorm:"column:request_time" json:"request_time"`
+	PurgeAfter  time.Time `gorm:"column:purge_after" json:"purge_after"`
+	UpdateTime  time.Time `gorm:"column:update_time" json:"update_time"`
+}
+
+func (AccountDeletion) TableName() string {
+	return "account_deletion"
+}
+
+const (
+	CONSENT_LOGIN   = "login"
+	CONSENT_BOOKING = "booking"
+)
+
+// GuardianConsent records a guardian agreeing to (or withdrawing) something
+// on behalf of a member under the consent age. Rows are never changed.
+type GuardianConsent struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	GuardianID uint64    `gorm:"column:guardian_id;index" json:"guardian_id"`
+	MemberID   uint64    `gorm:"column:member_id;index" json:"member_id"`
+	Purpose    string    `gorm:"column:purpose;size:16" json:"purpose"`
+	Granted    bool      `gorm:"column:granted" json:"granted"`
+	IP         string    `gorm:"column:ip;size:64" json:"ip"`
+	AddTime    time.Time `gorm:"column:add_time" json:"add_time"`
+}
+
+func (GuardianConsent) TableName() string {
+	return "guardian_consent"
+}
@@ -1,11 +1,10 @@
 package model
 
 import (
-	"crypto/sha256"
-	"fmt"
 	"time"
 
 	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/security"
 )
 
 type WalletGenerated struct {
@@ -58,36 +57,55 @@ func (WalletLog) TableName() string {
 	return "wallet_log"
 }
 
+const (
+	CHANNEL_STATUS_ACTIVE   = "00"
+	CHANNEL_STATUS_DISABLED = "01"
+)
+
+// SysChannel is one client app allowed to call the API. After a rotation the
+// previous credentials keep working until PrevExpire, so clients can switch
+// over without downtime.
 type SysChannel struct {
-	ID         uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	AppID      string    `gorm:"column:app_id" json:"app_id"`
-	AppKey     string    `gorm:"column:app_key;size:100" json:"app_key"`
-	Status     string    `gorm:"column:status" json:"status"`
-	Chan       string    `gorm:"column:chan" json:"chan"`
-	SigMethod  string    `gorm:"column:sig_method;size:255" json:"sig_method"`
-	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
-	UpdateTime time.Time `gorm:"column:update_time" json:"update_time"`
+	ID            uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	AppID         string    `gorm:"column:app_id" json:"app_id"`
+	AppKey        string    `gorm:"column:app_key;size:100" json:"-"`
+	Status        string    `gorm:"column:status" json:"status"`
+	Chan          string    `gorm:"column:chan" json:"chan"`
+	SigMethod     string    `gorm:"column:sig_method;size:255" json:"sig_method"`
+	PublicKey     string    `gorm:"column:public_key;size:255" json:"public_key"`
+	PrevAppKey    string    `gorm:"column:prev_app_key;size:100" json:"-"`
+	PrevPublicKey string    `gorm:"column:prev_public_key;size:255" json:"prev_public_key"`
+	PrevExpire    time.Time `gorm:"column:prev_expire" json:"prev_expire"`
+	CreateTime    time.Time `gorm:"column:create_time" json:"create_time"`
+	UpdateTime    time.Time `gorm:"column:update_time" json:"update_time"`
 }
 
 func (SysChannel) TableName() string {
 	return "sys_channel"
 }
 
-func (t *SysChannel) Verify(data, sig string) (bool, int) {
-	if t.SigMethod != "SHA256" {
+// Verify checks sig with the verifier registered for the channel's method.
+// HMAC-SHA256 uses AppKey as the secret, ED25519 the channel's PublicKey; the
+// previous credentials are tried too while the rotation overlap lasts.
+func (t *SysChannel) Verify(req security.SignedRequest, sig string) (bool, int) {
+	verifier, ok := security.LookupVerifier(t.SigMethod)
+	if !ok {
 		return false, codes.CODE_ERR_SIGMETHOD_UNSUPP
 	}
-	if len(data) == 0 || len(sig) == 0 {
+	if len(sig) == 0 {
 		return false, codes.CODE_ERR_AUTHTOKEN_FAIL
 	}
-	data = fmt.Sprintf("%s%s", data, t.AppKey)
-
-	hashByte := sha256.Sum256([]byte(data))
-	hash := fmt.Sprintf("%x", hashByte[:])
-	if hash != sig {
-		return false, codes.CODE_ERR_AUTHTOKEN_FAIL
+	keys := security.ChannelKeys{Secret: t.AppKey, PublicKey: t.PublicKey}
+	if verifier.Verify(req, keys, sig) {
+		return true, codes.CODE_SUCCESS
+	}
+	if time.Now().Before(t.PrevExpire) {
+		prev := security.ChannelKeys{Secret: t.PrevAppKey, PublicKey: t.PrevPublicKey}
+		if verifier.Verify(req, prev, sig) {
+			return true, codes.CODE_SUCCESS
+		}
 	}
-	return true, codes.CODE_SUCCESS
+	return false, codes.CODE_ERR_AUTHTOKEN_FAIL
 }
 
 type SysDes struct {
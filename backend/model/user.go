@@ -17,6 +17,7 @@ type UserWallet struct {
 	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
 	MainID     uint64    `gorm:"column:main_id" json:"main_id"`
 	RefID      uint64    `gorm:"column:ref_id" json:"ref_id"`
+	UserID     uint64    `gorm:"column:user_id;index" json:"user_id"` // linked user_info account, 0 if none
 }
 
 func (UserWallet) TableName() string {
@@ -40,13 +41,15 @@ func (auth AuthMessage) ComputeAuthDigest(base64Sig string) bool {
 	data := auth.Format()
 
 	publicKey, err := base58.Decode(auth.AuthKey)
-	if err != nil {
-		log.Println(err)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		log.Println("invalid wallet public key", auth.AuthKey, err)
+		return false
 	}
 
 	signature, err := base64.StdEncoding.DecodeString(base64Sig)
 	if err != nil {
 		log.Println(err)
+		return false
 	}
 
 	return ed25519.Verify(publicKey, []byte(data), signature)
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/mailer"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+	"github.com/redis/go-redis/v9"
+)
+
+// deferredKey is a sorted set of deliveries held back by quiet hours,
+// scored by the unix time they may go out.
+const deferredKey = "lb:notify:deferred"
+
+var errNoAddress = errors.New("user has no address for this channel")
+
+type delivery struct {
+	UserID  uint64  `json:"user_id"`
+	Event   string  `json:"event"`
+	Channel string  `json:"channel"`
+	Message Message `json:"message"`
+}
+
+func (d delivery) deliver() error {
+	switch d.Channel {
+	case model.NOTIFY_CHANNEL_INAPP:
+		return system.GetDb().Create(&model.Notification{
+			UserID:  d.UserID,
+			Event:   d.Event,
+			Title:   d.Message.Title,
+			Body:    d.Message.Body,
+			Link:    d.Message.Link,
+			AddTime: time.Now(),
+		}).Error
+	case model.NOTIFY_CHANNEL_WS:
+		return publish(d.UserID, d.Event, d.Message)
+	case model.NOTIFY_CHANNEL_EMAIL:
+		return d.email()
+	case model.NOTIFY_CHANNEL_SMS:
+		return d.sms()
+	}
+	return ErrUnknownEvent
+}
+
+func (d delivery) email() error {
+	var userInfo model.UserInfo
+	system.GetDb().Model(&model.UserInfo{}).Where("id = ? and status != ?", d.UserID, model.USER_STATUS_DELETED).First(&userInfo)
+	if len(userInfo.Email) == 0 {
+		return errNoAddress
+	}
+	unsubscribe, oneClick, err := UnsubscribeLinks(d.UserID, d.Event, d.Channel)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"List-Unsubscribe": "<" + unsubscribe + ">"}
+	if len(oneClick) > 0 {
+		headers["List-Unsubscribe"] = "<" + oneClick + ">"
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	body := d.Message.Body + "\n"
+	if len(d.Message.Link) > 0 {
+		body += "\n" + d.Message.Link + "\n"
+	}
+	body += fmt.Sprintf("\n--\nYou receive this because of your LangBridge notification settings. Stop these emails: %s\n", unsubscribe)
+	return mailer.Send(mailer.Message{
+		To:      userInfo.Email,
+		Subject: d.Message.Title,
+		Body:    body,
+		Headers: headers,
+	})
+}
+
+func (d delivery) sms() error {
+	var profile model.UserProfile
+	system.GetDb().Model(&model.UserProfile{}).Where("user_id = ?", d.UserID).First(&profile)
+	if len(profile.ContactPhone) == 0 {
+		return errNoAddress
+	}
+	text := d.Message.Title
+	if len(d.Message.Link) > 0 {
+		text += " " + d.Message.Link
+	}
+	return SMS().Send(profile.ContactPhone, text)
+}
+
+// SMSSender delivers a text message to an E.164 number.
+type SMSSender interface {
+	Send(to, text string) error
+}
+
+// logSMS is used until a provider is plugged in with SetSMS.
+type logSMS struct{}
+
+func (logSMS) Send(to, text string) error {
+	log.Info("[Notify] sms to ", to, ": ", text)
+	return nil
+}
+
+var (
+	smsSender SMSSender = logSMS{}
+	smsLock   sync.RWMutex
+)
+
+func SMS() SMSSender {
+	smsLock.RLock()
+	defer smsLock.RUnlock()
+	return smsSender
+}
+
+func SetSMS(s SMSSender) {
+	smsLock.Lock()
+	smsSender = s
+	smsLock.Unlock()
+}
+
+func hold(d delivery, until time.Time) error {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	// a nanosecond suffix keeps two identical messages from collapsing
+	member := strconv.FormatInt(time.Now().UnixNano(), 10) + "|" + string(raw)
+	return system.GetRedis().ZAdd(context.Background(), deferredKey, redis.Z{Score: float64(until.Unix()), Member: member}).Err()
+}
+
+// StartDeferredLoop sends held deliveries whose quiet hours are over. Every
+// instance runs it; removing an entry from the set is what claims it, so
+// each one goes out once.
+func StartDeferredLoop() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			sendDeferred()
+		}
+	}()
+}
+
+func sendDeferred() {
+	ctx := context.Background()
+	rdb := system.GetRedis()
+	due, err := rdb.ZRangeByScore(ctx, deferredKey, &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(time.Now().Unix(), 10), Count: 500}).Result()
+	if err != nil {
+		log.Error("[Notify] read deferred error: ", err)
+		return
+	}
+	for _, member := range due {
+		if n, err := rdb.ZRem(ctx, deferredKey, member).Result(); err != nil || n == 0 {
+			continue
+		}
+		var d delivery
+		_, raw, _ := strings.Cut(member, "|")
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			log.Error("[Notify] bad deferred entry: ", member)
+			continue
+		}
+		// the user may have opted out while it was waiting
+		if prefs, err := Load(d.UserID); err != nil || !prefs.Enabled(d.Event, d.Channel) {
+			continue
+		}
+		if err := d.deliver(); err != nil {
+			log.Error("[Notify] deliver deferred error: ", d.UserID, d.Event, d.Channel, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/timezone"
+	"github.com/langbridge/backend/utils"
+)
+
+// REMINDER_LEAD is how long before a lesson its reminder goes out.
+const REMINDER_LEAD = 2 * time.Hour
+
+const reminderInterval = 5 * time.Minute
+
+// lessonNames returns the course and teacher name of a booking.
+func lessonNames(b model.CourseBookTrans) (string, string) {
+	db := system.GetDb()
+	var course model.CourseInfo
+	var teacher model.Teacher
+	db.Model(&model.CourseInfo{}).Where("id = ?", b.CourseID).First(&course)
+	db.Model(&model.Teacher{}).Where("id = ?", b.TeacherID).First(&teacher)
+	return course.Name, teacher.Name
+}
+
+// lessonStart renders when a booked lesson starts, on the user's clock.
+func lessonStart(b model.CourseBookTrans, userID uint64) string {
+	start, _, err := utils.LessonSpan(b.LessonDate, b.StartTime, b.EndTime, timezone.Lesson())
+	if err != nil {
+		return b.LessonDate.Format("2006-01-02") + " " + b.StartTime
+	}
+	loc := timezone.ForUser(system.GetDb(), userID)
+	return start.In(loc).Format("Mon 2 Jan 2006 15:04") + " (" + loc.String() + ")"
+}
+
+// lessonRecipients is the account holder and, for a family member with a
+// login of their own, the member too.
+func lessonRecipients(b model.CourseBookTrans) []uint64 {
+	recipients := []uint64{b.UserID}
+	if b.MemberID > 0 {
+		var member model.UserMember
+		system.GetDb().Model(&model.UserMember{}).Where("id = ? and flag != ?", b.MemberID, -1).First(&member)
+		if member.LoginUserID > 0 {
+			recipients = append(recipients, member.LoginUserID)
+		}
+	}
+	return recipients
+}
+
+// BookingConfirmed tells the learner a booking went through.
+func BookingConfirmed(bookings []model.CourseBookTrans) {
+	if len(bookings) == 0 {
+		return
+	}
+	first := bookings[0]
+	course, teacher := lessonNames(first)
+	for _, userID := range lessonRecipients(first) {
+		Send(userID, model.NOTIFY_EVENT_BOOKING_CONFIRMED, Message{
+			Title: fmt.Sprintf("Booking confirmed: %s", course),
+			Body: fmt.Sprintf("Your booking %s of %d lesson(s) of %s with %s is confirmed. The first lesson starts %s.",
+				first.BookingNo, len(bookings), course, teacher, lessonStart(first, userID)),
+			Link: "/lessons",
+		})
+	}
+}
+
+// StartReminderLoop sends a reminder for each lesson starting within
+// REMINDER_LEAD.
+func StartReminderLoop() {
+	go func() {
+		ticker := time.NewTicker(reminderInterval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			remindDue()
+		}
+	}()
+}
+
+func remindDue() {
+	if first, err := system.Throttle("lb:notify:reminder", reminderInterval-30*time.Second); err != nil || !first {
+		return
+	}
+	store := timezone.Lesson()
+	now := time.Now()
+	from, _ := utils.WallClock(now, store)
+	to, _ := utils.WallClock(now.Add(REMINDER_LEAD), store)
+
+	var bookings []model.CourseBookTrans
+	err := system.GetDb().Model(&model.CourseBookTrans{}).
+		Where("status = ? and lesson_date >= ? and lesson_date <= ?", "000", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Find(&bookings).Error
+	if err != nil {
+		log.Error("[Notify] query lessons to remind error: ", err)
+		return
+	}
+	for _, b := range bookings {
+		start, _, err := utils.LessonSpan(b.LessonDate, b.StartTime, b.EndTime, store)
+		if err != nil || start.Before(now) || start.After(now.Add(REMINDER_LEAD)) {
+			continue
+		}
+		key := fmt.Sprintf("lb:notify:reminded:%d", b.ID)
+		if ok, err := system.GetRedis().SetNX(context.Background(), key, now.Unix(), 2*REMINDER_LEAD).Result(); err != nil || !ok {
+			continue
+		}
+		course, teacher := lessonNames(b)
+		for _, userID := range lessonRecipients(b) {
+			Send(userID, model.NOTIFY_EVENT_LESSON_REMINDER, Message{
+				Title: fmt.Sprintf("Lesson soon: %s", course),
+				Body:  fmt.Sprintf("Your %s lesson with %s starts %s.", course, teacher, lessonStart(b, userID)),
+				Link:  fmt.Sprintf("/lessons/meeting?btid=%d", b.ID),
+			})
+		}
+	}
+}
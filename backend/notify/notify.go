@@ -0,0 +1,229 @@
+// Package notify sends user-facing notifications (bookings, reminders,
+// receipts, ...) over email, SMS, the in-app inbox and live WebSocket pushes,
+// honouring each user's per-event, per-channel preferences and quiet hours.
+//
+// Account mail (email verification, password reset, member invites,
+// deletion notices) is transactional and does not go through here: a user
+// cannot opt out of it.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+	"github.com/langbridge/backend/timezone"
+	"github.com/langbridge/backend/utils"
+	"gorm.io/gorm"
+)
+
+const UNSUBSCRIBE_TTL = 365 * 24 * time.Hour
+
+var (
+	// Booking cancellation and review reply events are left out until the
+	// tree has a cancel path and review replies to send them from; a
+	// preference nothing honours would only mislead.
+	EVENTS = []string{
+		model.NOTIFY_EVENT_BOOKING_CONFIRMED,
+		model.NOTIFY_EVENT_LESSON_REMINDER,
+		model.NOTIFY_EVENT_PAYMENT_RECEIPT,
+	}
+	// in-app comes before ws so a push is never ahead of the inbox
+	CHANNELS = []string{
+		model.NOTIFY_CHANNEL_INAPP,
+		model.NOTIFY_CHANNEL_WS,
+		model.NOTIFY_CHANNEL_EMAIL,
+		model.NOTIFY_CHANNEL_SMS,
+	}
+)
+
+var ErrUnknownEvent = errors.New("unknown notification event or channel")
+
+// Message is the content of one notification; every channel renders it in
+// its own way.
+type Message struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Link  string `json:"link"` // frontend path or URL to open, may be empty
+}
+
+func ValidEvent(event string) bool {
+	return slices.Contains(EVENTS, event)
+}
+
+func ValidChannel(channel string) bool {
+	return slices.Contains(CHANNELS, channel)
+}
+
+// enabledByDefault applies when the user has not chosen. SMS costs money
+// and needs a phone number, so it is opt-in.
+func enabledByDefault(event, channel string) bool {
+	return channel != model.NOTIFY_CHANNEL_SMS
+}
+
+// quietChannel reports whether quiet hours hold a channel back. In-app and
+// WebSocket notifications only show while the user has the app open.
+func quietChannel(channel string) bool {
+	return channel == model.NOTIFY_CHANNEL_EMAIL || channel == model.NOTIFY_CHANNEL_SMS
+}
+
+// Preferences is the full event x channel matrix for one user, with the
+// defaults filled in.
+type Preferences struct {
+	Events      []string                 `json:"events"`
+	Channels    []string                 `json:"channels"`
+	Preferences []model.NotifyPreference `json:"preferences"`
+	QuietStart  string                   `json:"quiet_start"`
+	QuietEnd    string                   `json:"quiet_end"`
+	Timezone    string                   `json:"timezone"` // quiet hours are read in this zone
+}
+
+func (p *Preferences) Enabled(event, channel string) bool {
+	for _, pref := range p.Preferences {
+		if pref.Event == event && pref.Channel == channel {
+			return pref.Enabled
+		}
+	}
+	return false
+}
+
+func Load(userID uint64) (*Preferences, error) {
+	db := system.GetDb()
+	var stored []model.NotifyPreference
+	if err := db.Model(&model.NotifyPreference{}).Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	var setting model.NotifySetting
+	if err := db.Model(&model.NotifySetting{}).Where("user_id = ?", userID).First(&setting).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	p := &Preferences{
+		Events:     EVENTS,
+		Channels:   CHANNELS,
+		QuietStart: setting.QuietStart,
+		QuietEnd:   setting.QuietEnd,
+		Timezone:   timezone.ForUser(db, userID).String(),
+	}
+	for _, event := range EVENTS {
+		for _, channel := range CHANNELS {
+			pref := model.NotifyPreference{Event: event, Channel: channel, Enabled: enabledByDefault(event, channel)}
+			for _, s := range stored {
+				if s.Event == event && s.Channel == channel {
+					pref.Enabled = s.Enabled
+				}
+			}
+			p.Preferences = append(p.Preferences, pref)
+		}
+	}
+	return p, nil
+}
+
+// SetPreference stores the user's choice for one event on one channel.
+func SetPreference(db *gorm.DB, userID uint64, event, channel string, enabled bool) error {
+	if !ValidEvent(event) || !ValidChannel(channel) {
+		return ErrUnknownEvent
+	}
+	var pref model.NotifyPreference
+	db.Model(&model.NotifyPreference{}).Where("user_id = ? and event = ? and channel = ?", userID, event, channel).First(&pref)
+	if pref.ID > 0 {
+		return db.Model(&model.NotifyPreference{}).Where("id = ?", pref.ID).
+			Updates(map[string]interface{}{"enabled": enabled, "update_time": time.Now()}).Error
+	}
+	return db.Create(&model.NotifyPreference{
+		UserID:     userID,
+		Event:      event,
+		Channel:    channel,
+		Enabled:    enabled,
+		UpdateTime: time.Now(),
+	}).Error
+}
+
+// SetQuietHours stores the quiet window; two empty strings turn it off.
+func SetQuietHours(db *gorm.DB, userID uint64, start, end string) error {
+	var setting model.NotifySetting
+	db.Model(&model.NotifySetting{}).Where("user_id = ?", userID).First(&setting)
+	if setting.ID > 0 {
+		return db.Model(&model.NotifySetting{}).Where("id = ?", setting.ID).
+			Updates(map[string]interface{}{"quiet_start": start, "quiet_end": end, "update_time": time.Now()}).Error
+	}
+	return db.Create(&model.NotifySetting{UserID: userID, QuietStart: start, QuietEnd: end, UpdateTime: time.Now()}).Error
+}
+
+// Send delivers msg to the user on every channel they have enabled for
+// event. Email and SMS falling in the user's quiet hours are queued until
+// the hours end. Delivery errors are logged, never returned: a failed
+// notification must not fail the action that caused it.
+func Send(userID uint64, event string, msg Message) {
+	prefs, err := Load(userID)
+	if err != nil {
+		log.Error("[Notify] load preferences error: ", userID, err)
+		return
+	}
+	loc, _ := utils.LoadZone(prefs.Timezone)
+	if loc == nil {
+		loc = time.UTC
+	}
+	quietEnd, quiet := utils.QuietUntil(time.Now(), loc, prefs.QuietStart, prefs.QuietEnd)
+
+	for _, channel := range CHANNELS {
+		if !prefs.Enabled(event, channel) {
+			continue
+		}
+		d := delivery{UserID: userID, Event: event, Channel: channel, Message: msg}
+		if quiet && quietChannel(channel) {
+			if err := hold(d, quietEnd); err != nil {
+				log.Error("[Notify] queue for quiet hours error: ", userID, channel, err)
+			}
+			continue
+		}
+		if err := d.deliver(); err != nil {
+			log.Error("[Notify] deliver error: ", userID, event, channel, err)
+		}
+	}
+}
+
+func unsubscribePurpose(event, channel string) string {
+	return fmt.Sprintf("%s:%s:%s", security.ACTION_UNSUBSCRIBE, event, channel)
+}
+
+// UnsubscribeLinks are the links put in every notification email: page is
+// the frontend page for people, oneClick the API endpoint mail clients post
+// to for one-click unsubscribe (RFC 8058), "" when no API origin is set.
+func UnsubscribeLinks(userID uint64, event, channel string) (page, oneClick string, err error) {
+	token, err := security.IssueLinkToken(unsubscribePurpose(event, channel), userID, UNSUBSCRIBE_TTL)
+	if err != nil {
+		return "", "", err
+	}
+	q := url.Values{}
+	q.Set("event", event)
+	q.Set("channel", channel)
+	q.Set("token", token)
+	conf := config.GetConfig().Auth
+	page = fmt.Sprintf("%s/unsubscribe?%s", strings.TrimRight(conf.WebBase, "/"), q.Encode())
+	if len(conf.ApiBase) > 0 {
+		oneClick = fmt.Sprintf("%s/notify/unsubscribe?%s", strings.TrimRight(conf.ApiBase, "/"), q.Encode())
+	}
+	return page, oneClick, nil
+}
+
+// Unsubscribe turns off the event on the channel the token was issued for
+// and returns the user it belongs to.
+func Unsubscribe(event, channel, token string) (uint64, error) {
+	if !ValidEvent(event) || !ValidChannel(channel) {
+		return 0, ErrUnknownEvent
+	}
+	userID, err := security.ParseLinkToken(unsubscribePurpose(event, channel), token)
+	if err != nil {
+		return 0, err
+	}
+	return userID, SetPreference(system.GetDb(), userID, event, channel, false)
+}
@@ -0,0 +1,15 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/langbridge/backend/model"
+)
+
+// PaymentReceived sends the receipt for a transaction the user reported.
+func PaymentReceived(tx model.TxsDiagram) {
+	Send(tx.UserID, model.NOTIFY_EVENT_PAYMENT_RECEIPT, Message{
+		Title: "Payment received",
+		Body:  fmt.Sprintf("We received your payment #%d on %s, transaction %s.", tx.ID, tx.Chain, tx.TxHash),
+	})
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/system"
+)
+
+// Pushes go through Redis so a user connected to another instance gets them
+// too; every instance writes to the connections it holds.
+
+type pushMessage struct {
+	UserID  uint64  `json:"user_id"`
+	Event   string  `json:"event"`
+	Message Message `json:"message"`
+}
+
+// Client is one WebSocket connection of a signed-in user. gorilla/websocket
+// allows a single writer, so every write goes through WriteMessage.
+type Client struct {
+	userID uint64
+	conn   *websocket.Conn
+	mu     sync.Mutex
+}
+
+func (c *Client) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+var (
+	clients   = map[uint64]map[*Client]bool{}
+	clientsMu sync.Mutex
+)
+
+// Attach registers conn to receive the user's pushes until Detach.
+func Attach(userID uint64, conn *websocket.Conn) *Client {
+	c := &Client{userID: userID, conn: conn}
+	clientsMu.Lock()
+	if clients[userID] == nil {
+		clients[userID] = map[*Client]bool{}
+	}
+	clients[userID][c] = true
+	clientsMu.Unlock()
+	return c
+}
+
+func Detach(c *Client) {
+	clientsMu.Lock()
+	delete(clients[c.userID], c)
+	if len(clients[c.userID]) == 0 {
+		delete(clients, c.userID)
+	}
+	clientsMu.Unlock()
+}
+
+func publish(userID uint64, event string, msg Message) error {
+	raw, err := json.Marshal(pushMessage{UserID: userID, Event: event, Message: msg})
+	if err != nil {
+		return err
+	}
+	return system.GetRedis().Publish(context.Background(), codes.TOPIC_NOTIFY_PUSH, raw).Err()
+}
+
+// WatchPush forwards published pushes to the local connections of their
+// user. It blocks; run it on its own goroutine.
+func WatchPush() {
+	pubsub := system.GetRedis().Subscribe(context.Background(), codes.TOPIC_NOTIFY_PUSH)
+	for msg := range pubsub.Channel() {
+		var push pushMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &push); err != nil {
+			log.Error("[Notify] bad push message: ", err)
+			continue
+		}
+		clientsMu.Lock()
+		targets := make([]*Client, 0, len(clients[push.UserID]))
+		for c := range clients[push.UserID] {
+			targets = append(targets, c)
+		}
+		clientsMu.Unlock()
+
+		payload, _ := json.Marshal(map[string]any{"type": "notification", "event": push.Event, "data": push.Message})
+		for _, c := range targets {
+			if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+				log.Info("[Notify] push write error: ", push.UserID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockIdP is a minimal OIDC provider: discovery, JWKS and a token endpoint
+// that checks PKCE and returns whatever ID token the test set up.
+type mockIdP struct {
+	*httptest.Server
+	rsaKey    *rsa.PrivateKey
+	ecKey     *ecdsa.PrivateKey
+	challenge string // code_challenge from the authorization request
+	idToken   string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	m := &mockIdP{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{
+			{Kty: "RSA", Kid: "rsa1", Use: "sig", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{Kty: "EC", Kid: "ec1", Crv: "P-256", X: b64(ecKey.X.FillBytes(make([]byte, 32))), Y: b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || pkceChallenge(r.Form.Get("code_verifier")) != m.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(TokenResponse{AccessToken: "at", TokenType: "Bearer", IDToken: m.idToken})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIdP) sign(t *testing.T, alg string, claims map[string]any) string {
+	t.Helper()
+	kid := map[string]string{"RS256": "rsa1", "ES256": "ec1"}[alg]
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Kid: kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, m.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, m.ecKey, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (m *mockIdP) claims(nonce string) map[string]any {
+	return map[string]any{
+		"iss":            m.URL,
+		"sub":            "user-123",
+		"aud":            "client-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "parent@example.com",
+		"email_verified": "true",
+		"locale":         "en-GB",
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+	p, err := Discover(ctx, Config{Name: "mock", Issuer: idp.URL, ClientID: "client-1", RedirectURL: "http://app/cb"}, idp.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, challenge, _ := NewPKCE()
+	authURL, _ := url.Parse(p.AuthCodeURL("st", "n-1", challenge))
+	if authURL.Query().Get("code_challenge_method") != "S256" || authURL.Query().Get("nonce") != "n-1" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	idp.challenge = authURL.Query().Get("code_challenge")
+	idp.idToken = idp.sign(t, "RS256", idp.claims("n-1"))
+
+	if _, err := p.Exchange(ctx, "good-code", "wrong-verifier"); !errors.Is(err, ErrExchange) {
+		t.Fatalf("expected PKCE mismatch to fail, got %v", err)
+	}
+	tok, err := p.Exchange(ctx, "good-code", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.VerifyIDToken(ctx, tok.IDToken, "n-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "user-123" || !bool(id.EmailVerified) || id.Locale != "en-GB" {
+		t.Fatalf("unexpected id token %+v", id)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+	p, err := Discover(ctx, Config{Issuer: idp.URL, ClientID: "client-1"}, idp.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.VerifyIDToken(ctx, idp.sign(t, "ES256", idp.claims("n")), "n"); err != nil {
+		t.Fatalf("ES256 token refused: %v", err)
+	}
+
+	cases := map[string]func(map[string]any){
+		"nonce":    func(c map[string]any) { c["nonce"] = "other" },
+		"audience": func(c map[string]any) { c["aud"] = []string{"client-2"} },
+		"issuer":   func(c map[string]any) { c["iss"] = "https://evil.example" },
+		"expired":  func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+	for name, mutate := range cases {
+		claims := idp.claims("n")
+		mutate(claims)
+		if _, err := p.VerifyIDToken(ctx, idp.sign(t, "RS256", claims), "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected rejection, got %v", name, err)
+		}
+	}
+
+	good := idp.sign(t, "RS256", idp.claims("n"))
+	parts := strings.Split(good, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+	if _, err := p.VerifyIDToken(ctx, tampered, "n"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("tampered token accepted: %v", err)
+	}
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa1"}`)) + "." + parts[1] + "."
+	if _, err := p.VerifyIDToken(ctx, none, "n"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("alg none accepted: %v", err)
+	}
+}
+
+func TestClientSecret(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if _, err := ParseSecretKey("TEAM", "", keyPEM); !errors.Is(err, ErrSecretKey) {
+		t.Fatalf("missing key id accepted: %v", err)
+	}
+	k, err := ParseSecretKey("TEAM", "KEY1", keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	secret, err := k.ClientSecret("com.langbridge.web", "https://appleid.apple.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(secret, ".")
+	var header jwtHeader
+	var claims map[string]any
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "ES256" || header.Kid != "KEY1" {
+		t.Fatalf("header %+v %v", header, err)
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil || claims["iss"] != "TEAM" || claims["sub"] != "com.langbridge.web" ||
+		claims["aud"] != "https://appleid.apple.com" || int64(claims["exp"].(float64)) != now.Add(SECRET_TTL).Unix() {
+		t.Fatalf("claims %v %v", claims, err)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if err := verifySignature("ES256", &ecKey.PublicKey, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"time"
+)
+
+// SECRET_TTL is how long a signed client secret is used for. Apple allows
+// up to six months; a fresh one per exchange keeps a leaked one short-lived.
+const SECRET_TTL = 5 * time.Minute
+
+var ErrSecretKey = errors.New("oidc client secret key invalid")
+
+// SecretKey signs client secrets for providers that take a JWT instead of a
+// static secret, as Sign in with Apple does: the team and key IDs and the
+// P-256 key (.p8) from the developer account.
+type SecretKey struct {
+	TeamID string
+	KeyID  string
+	Key    *ecdsa.PrivateKey
+}
+
+// ParseSecretKey reads a PKCS #8 PEM private key.
+func ParseSecretKey(teamID, keyID, keyPEM string) (*SecretKey, error) {
+	if len(teamID) == 0 || len(keyID) == 0 {
+		return nil, errors.Join(ErrSecretKey, errors.New("team and key id are required"))
+	}
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.Join(ErrSecretKey, errors.New("no PEM block"))
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Join(ErrSecretKey, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve.Params().BitSize != 256 {
+		return nil, errors.Join(ErrSecretKey, errors.New("not a P-256 key"))
+	}
+	return &SecretKey{TeamID: teamID, KeyID: keyID, Key: key}, nil
+}
+
+// ClientSecret returns an ES256 JWT identifying clientID to the provider at
+// audience.
+func (k *SecretKey) ClientSecret(clientID, audience string, now time.Time) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "ES256", Kid: k.KeyID})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": k.TeamID,
+		"iat": now.Unix(),
+		"exp": now.Add(SECRET_TTL).Unix(),
+		"aud": audience,
+		"sub": clientID,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, k.Key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
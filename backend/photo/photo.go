@@ -0,0 +1,228 @@
+// Package photo is the upload path shared by user avatars and teacher
+// profile photos: read the multipart file, turn it into square renditions
+// and put them in storage under one prefix.
+package photo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/imaging"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/storage"
+)
+
+const DEFAULT_MAX_UPLOAD = 5 << 20
+
+var (
+	ErrNoFile   = errors.New("no file uploaded")
+	ErrTooLarge = errors.New("file too large")
+	ErrStorage  = errors.New("file storage unavailable")
+	ErrNotOwned = errors.New("not one of your uploads")
+)
+
+// SIZES are the renditions kept for every photo. "main" is the URL stored
+// on the profile; the others are thumbnails for lists and chat.
+var SIZES = []imaging.Size{
+	{Name: "main", Pixels: 512},
+	{Name: "medium", Pixels: 128},
+	{Name: "small", Pixels: 64},
+}
+
+// Photo is what a client needs after an upload.
+type Photo struct {
+	URL        string            `json:"url"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+// AvatarDir and TeacherDir are where a user's avatars and a teacher's
+// photos are stored. Nothing outside them is ever removed on their behalf.
+func AvatarDir(userNo string) string {
+	return "avatars/" + userNo
+}
+
+func TeacherDir(teacherID uint64) string {
+	return "teachers/" + strconv.FormatUint(teacherID, 10)
+}
+
+func MaxUploadSize() int64 {
+	if n := config.GetConfig().Storage.MaxUploadSize; n > 0 {
+		return n
+	}
+	return DEFAULT_MAX_UPLOAD
+}
+
+// Read returns the content of the multipart field, refusing anything over
+// limit without reading past it.
+func Read(c *gin.Context, field string, limit int64) ([]byte, error) {
+	// headers and boundaries come on top of the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+64<<10)
+	fh, err := c.FormFile(field)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, ErrTooLarge
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoFile, err)
+	}
+	if fh.Size > limit {
+		return nil, ErrTooLarge
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Upload reads the "file" field of the request and saves it under dir.
+// Refusals are ErrStorage, ErrTooLarge, ErrNoFile or the imaging errors.
+func Upload(c *gin.Context, dir string) (*Photo, error) {
+	st, err := storage.Default()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	data, err := Read(c, "file", MaxUploadSize())
+	if err != nil {
+		return nil, err
+	}
+	p, err := Save(c.Request.Context(), st, dir, data)
+	if err != nil {
+		return nil, fmt.Errorf("save %s: %w", dir, err)
+	}
+	return p, nil
+}
+
+// Discard removes a photo under dir that was replaced. Failures only leave
+// an orphaned file behind, so they are logged and not returned.
+func Discard(ctx context.Context, dir, mainURL string) {
+	if len(mainURL) == 0 {
+		return
+	}
+	st, err := storage.Default()
+	if err != nil {
+		return
+	}
+	if err := Remove(ctx, st, dir, mainURL); err != nil {
+		log.Error("[Photo] remove error: ", mainURL, err)
+	}
+}
+
+// CheckURL refuses a URL on the configured storage that is not under dir,
+// so a profile cannot claim someone else's upload and have it removed when
+// replaced. URLs elsewhere are the caller's business.
+func CheckURL(dir, rawURL string) error {
+	st, err := storage.Default()
+	if err != nil {
+		return nil
+	}
+	return Check(st, dir, rawURL)
+}
+
+func Check(st storage.Storage, dir, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	base, err := url.Parse(st.URL(""))
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil
+	}
+	if _, ok := ownedKey(st, dir, rawURL); !ok {
+		return ErrNotOwned
+	}
+	return nil
+}
+
+// ownedKey returns the storage key of rawURL if it lies under dir.
+func ownedKey(st storage.Storage, dir, rawURL string) (string, bool) {
+	key, ok := strings.CutPrefix(rawURL, st.URL(""))
+	if !ok {
+		return "", false
+	}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	if len(prefix) < 2 || !strings.HasPrefix(key, prefix) || path.Clean(key) != key {
+		return "", false
+	}
+	return key, true
+}
+
+// Save processes data and stores every rendition as
+// <dir>/<random>-<size name>.<ext>. If a rendition fails to store, the ones
+// already stored are removed again.
+func Save(ctx context.Context, st storage.Storage, dir string, data []byte) (*Photo, error) {
+	renditions, err := imaging.Square(data, SIZES)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(dir, "/") + "/" + hex.EncodeToString(b)
+
+	p := &Photo{Thumbnails: map[string]string{}}
+	var stored []string
+	for _, r := range renditions {
+		key := base + "-" + r.Name + "." + r.Ext
+		if err := st.Put(ctx, key, r.Data, r.ContentType); err != nil {
+			for _, k := range stored {
+				st.Delete(ctx, k)
+			}
+			return nil, err
+		}
+		stored = append(stored, key)
+		if r.Name == SIZES[0].Name {
+			p.URL = st.URL(key)
+		} else {
+			p.Thumbnails[r.Name] = st.URL(key)
+		}
+	}
+	return p, nil
+}
+
+// Remove deletes the renditions of a photo stored by Save under dir, given
+// the URL of its main rendition. URLs that do not point into st, such as
+// avatars set by URL before uploads existed, are left alone; ones pointing
+// into st outside dir are refused with ErrNotOwned.
+func Remove(ctx context.Context, st storage.Storage, dir, mainURL string) error {
+	if _, ok := strings.CutPrefix(mainURL, st.URL("")); !ok {
+		return nil
+	}
+	key, ok := ownedKey(st, dir, mainURL)
+	if !ok {
+		return ErrNotOwned
+	}
+	dot := strings.LastIndexByte(key, '.')
+	if dot < 0 {
+		return nil
+	}
+	base, ok := strings.CutSuffix(key[:dot], "-"+SIZES[0].Name)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, size := range SIZES {
+		errs = append(errs, st.Delete(ctx, base+"-"+size.Name+key[dot:]))
+	}
+	return errors.Join(errs...)
+}
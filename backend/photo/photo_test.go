@@ -0,0 +1,74 @@
+package photo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/storage"
+)
+
+func TestSaveRemove(t *testing.T) {
+	dir := t.TempDir()
+	st, err := storage.NewLocal(config.LocalStorageConfig{Dir: dir, BaseURL: "http://localhost/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 600, 400)))
+
+	ctx := context.Background()
+	p, err := Save(ctx, st, "avatars/10001", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.URL, "http://localhost/files/avatars/10001/") || !strings.HasSuffix(p.URL, "-main.png") {
+		t.Fatalf("unexpected url %s", p.URL)
+	}
+	if len(p.Thumbnails) != len(SIZES)-1 {
+		t.Fatalf("unexpected thumbnails %v", p.Thumbnails)
+	}
+	files, _ := os.ReadDir(filepath.Join(dir, "avatars", "10001"))
+	if len(files) != len(SIZES) {
+		t.Fatalf("expected %d files, got %d", len(SIZES), len(files))
+	}
+
+	if err := Remove(ctx, st, "avatars/10001", "https://cdn.example.com/someone-else.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(ctx, st, "avatars/1000", p.URL); !errors.Is(err, ErrNotOwned) {
+		t.Fatalf("removed from another prefix: %v", err)
+	}
+	if err := Remove(ctx, st, "avatars/10001", p.URL); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = os.ReadDir(filepath.Join(dir, "avatars", "10001"))
+	if len(files) != 0 {
+		t.Fatalf("renditions left behind: %d", len(files))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	st, err := storage.NewLocal(config.LocalStorageConfig{Dir: t.TempDir(), BaseURL: "http://localhost/files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]error{
+		"https://cdn.example.com/a.png":                         nil,
+		"http://localhost/files/avatars/10001/ab-main.png":      nil,
+		"http://localhost/files/avatars/10002/ab-main.png":      ErrNotOwned,
+		"http://localhost/files/avatars/10001/../2/ab-main.png": ErrNotOwned,
+		"http://LOCALHOST/other/avatars/10001/ab-main.png":      ErrNotOwned,
+	} {
+		if err := Check(st, "avatars/10001", url); !errors.Is(err, want) {
+			t.Errorf("Check(%s) = %v, want %v", url, err, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package placement runs the adaptive placement test that gives a learner a
+// CEFR level. Questions come from an item bank tagged by skill and band; the
+// test is a staircase that steps one band up after a right answer and one
+// down after a wrong one, so it settles around the learner's level within a
+// few questions.
+//
+// Levels are stored as ints so they compare with CourseInfo.Level: 1 is A1
+// and 6 is C2, 0 means the learner has not been placed.
+package placement
+
+import "slices"
+
+const (
+	SKILL_GRAMMAR    = "grammar"
+	SKILL_VOCABULARY = "vocabulary"
+	SKILL_READING    = "reading"
+	SKILL_LISTENING  = "listening"
+)
+
+const (
+	MIN_ITEMS   = 8
+	MAX_ITEMS   = 20
+	START_LEVEL = 3 // B1, the middle of the scale
+
+	// a band is passed with at least PASS_PERCENT right out of MIN_PER_BAND
+	// or more questions at it
+	PASS_PERCENT = 60
+	MIN_PER_BAND = 2
+
+	// the test stops early once the last SETTLE_WINDOW questions all sat on
+	// two neighbouring bands
+	SETTLE_WINDOW = 6
+)
+
+var (
+	BANDS  = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+	SKILLS = []string{SKILL_GRAMMAR, SKILL_VOCABULARY, SKILL_READING, SKILL_LISTENING}
+)
+
+// Band names a level, "" for 0 or out of range.
+func Band(level int) string {
+	if level < 1 || level > len(BANDS) {
+		return ""
+	}
+	return BANDS[level-1]
+}
+
+// Level parses a band name such as "B2".
+func Level(band string) (int, bool) {
+	i := slices.Index(BANDS, band)
+	return i + 1, i >= 0
+}
+
+func ValidSkill(skill string) bool {
+	return slices.Contains(SKILLS, skill)
+}
+
+// Answer is one question the learner has answered.
+type Answer struct {
+	ItemID  uint64 `json:"item_id"`
+	Skill   string `json:"skill"`
+	Level   int    `json:"level"`
+	Correct bool   `json:"correct"`
+}
+
+// Step is what to ask next: an item at Level testing Skill.
+type Step struct {
+	Level int
+	Skill string
+}
+
+// Next picks the band and skill of the next question, or returns false when
+// the test is over.
+func Next(answers []Answer) (Step, bool) {
+	n := len(answers)
+	if n >= MAX_ITEMS || (n >= MIN_ITEMS && settled(answers)) {
+		return Step{}, false
+	}
+	step := Step{Level: START_LEVEL, Skill: SKILLS[n%len(SKILLS)]}
+	if n > 0 {
+		last := answers[n-1]
+		step.Level = last.Level
+		if last.Correct {
+			step.Level++
+		} else {
+			step.Level--
+		}
+		step.Level = min(max(step.Level, 1), len(BANDS))
+	}
+	return step, true
+}
+
+func settled(answers []Answer) bool {
+	if len(answers) < SETTLE_WINDOW {
+		return false
+	}
+	lo, hi := len(BANDS), 1
+	for _, a := range answers[len(answers)-SETTLE_WINDOW:] {
+		lo, hi = min(lo, a.Level), max(hi, a.Level)
+	}
+	return hi-lo <= 1
+}
+
+// Result is the outcome of a finished test.
+type Result struct {
+	Level  int            `json:"level"`
+	Band   string         `json:"band"`
+	Skills map[string]int `json:"skills"` // level per skill the test covered
+}
+
+// Score places the learner at the highest band they passed, A1 if none.
+// Skills are scored the same way but a single question per band counts,
+// since each skill only gets a share of the test.
+func Score(answers []Answer) Result {
+	level := passed(answers, MIN_PER_BAND)
+	r := Result{Level: level, Band: Band(level), Skills: map[string]int{}}
+	for _, skill := range SKILLS {
+		var of []Answer
+		for _, a := range answers {
+			if a.Skill == skill {
+				of = append(of, a)
+			}
+		}
+		if len(of) > 0 {
+			r.Skills[skill] = passed(of, 1)
+		}
+	}
+	return r
+}
+
+func passed(answers []Answer, minCount int) int {
+	var asked, right [7]int
+	for _, a := range answers {
+		if a.Level < 1 || a.Level > len(BANDS) {
+			continue
+		}
+		asked[a.Level]++
+		if a.Correct {
+			right[a.Level]++
+		}
+	}
+	for level := len(BANDS); level > 1; level-- {
+		if asked[level] >= minCount && right[level]*100 >= asked[level]*PASS_PERCENT {
+			return level
+		}
+	}
+	return 1
+}
@@ -0,0 +1,76 @@
+package placement
+
+import "testing"
+
+// run answers the test as a learner who gets every question at or below
+// level right and every one above it wrong.
+func run(level int) []Answer {
+	var answers []Answer
+	for {
+		step, ok := Next(answers)
+		if !ok {
+			return answers
+		}
+		answers = append(answers, Answer{
+			ItemID:  uint64(len(answers) + 1),
+			Skill:   step.Skill,
+			Level:   step.Level,
+			Correct: step.Level <= level,
+		})
+	}
+}
+
+func TestPlacesLearner(t *testing.T) {
+	for level := 1; level <= len(BANDS); level++ {
+		answers := run(level)
+		if len(answers) < MIN_ITEMS || len(answers) > MAX_ITEMS {
+			t.Fatalf("level %d: %d questions", level, len(answers))
+		}
+		if r := Score(answers); r.Level != level || r.Band != BANDS[level-1] {
+			t.Errorf("level %d scored %d (%s) after %d questions", level, r.Level, r.Band, len(answers))
+		}
+	}
+}
+
+func TestNextStaircase(t *testing.T) {
+	step, ok := Next(nil)
+	if !ok || step.Level != START_LEVEL || step.Skill != SKILLS[0] {
+		t.Fatalf("first step %+v", step)
+	}
+	step, _ = Next([]Answer{{Level: 6, Correct: true}})
+	if step.Level != 6 || step.Skill != SKILLS[1] {
+		t.Errorf("step above C2: %+v", step)
+	}
+	step, _ = Next([]Answer{{Level: 1, Correct: false}})
+	if step.Level != 1 {
+		t.Errorf("step below A1: %+v", step)
+	}
+}
+
+func TestScoreNeedsEnoughAnswers(t *testing.T) {
+	// one lucky C1 answer is not a C1 pass
+	r := Score([]Answer{
+		{Skill: SKILL_GRAMMAR, Level: 3, Correct: true},
+		{Skill: SKILL_VOCABULARY, Level: 3, Correct: true},
+		{Skill: SKILL_READING, Level: 5, Correct: true},
+		{Skill: SKILL_LISTENING, Level: 4, Correct: false},
+	})
+	if r.Level != 3 {
+		t.Errorf("level %d, want 3", r.Level)
+	}
+	if r.Skills[SKILL_READING] != 5 || r.Skills[SKILL_LISTENING] != 1 {
+		t.Errorf("skills %v", r.Skills)
+	}
+}
+
+func TestBand(t *testing.T) {
+	if Band(0) != "" || Band(7) != "" || Band(4) != "B2" {
+		t.Fatal("Band")
+	}
+	if l, ok := Level("C1"); !ok || l != 5 {
+		t.Fatal("Level")
+	}
+	if _, ok := Level("D1"); ok {
+		t.Fatal("Level accepted D1")
+	}
+}
@@ -0,0 +1,197 @@
+// Package progress rolls a learner's completed lessons and placement
+// results up into where they stand and how far they are from their goals.
+//
+// Projections use guided learning hours: roughly how many hours of taught
+// study it takes to reach each CEFR level from scratch. The learner's
+// current level is placed on that scale, the hours studied since it was
+// assessed are added, and their recent weekly pace says when they will
+// cover the rest.
+package progress
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/placement"
+)
+
+const (
+	// PACE_WINDOW is how far back the weekly pace looks.
+	PACE_WINDOW = 8 * 7 * 24 * time.Hour
+
+	DATE_FORMAT = "2006-01-02"
+)
+
+// GUIDED_HOURS is the cumulative study to reach each level, indexed like
+// CourseInfo.Level (1 A1 to 6 C2), after Cambridge English's guidance.
+var GUIDED_HOURS = [...]float64{0, 100, 200, 400, 600, 800, 1100}
+
+// EXAMS maps the Cambridge English exams a goal can name to the level they
+// certify.
+var EXAMS = map[string]int{
+	"KET": 2, // A2 Key
+	"PET": 3, // B1 Preliminary
+	"FCE": 4, // B2 First
+	"CAE": 5, // C1 Advanced
+	"CPE": 6, // C2 Proficiency
+}
+
+// ExamLevel returns the level an exam certifies, matching the name in any
+// case.
+func ExamLevel(exam string) (string, int, bool) {
+	exam = strings.ToUpper(strings.TrimSpace(exam))
+	level, ok := EXAMS[exam]
+	return exam, level, ok
+}
+
+// Lesson is a booked lesson; only those that have ended count.
+type Lesson struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Assessment is a finished placement test.
+type Assessment struct {
+	Level  int
+	Skills map[string]int
+	At     time.Time
+}
+
+type Skill struct {
+	Skill  string `json:"skill"`
+	Level  int    `json:"level"`
+	Band   string `json:"band"`
+	Change int    `json:"change"` // levels gained since the assessment before, 0 with only one
+}
+
+// Summary is where the learner stands, regardless of goals.
+type Summary struct {
+	Level            int        `json:"level"`
+	Band             string     `json:"band"`
+	AssessedAt       *time.Time `json:"assessed_at"` // latest placement test, nil if none
+	LessonsCompleted int        `json:"lessons_completed"`
+	HoursCompleted   float64    `json:"hours_completed"`
+	WeeklyHours      float64    `json:"weekly_hours"` // average over PACE_WINDOW
+	Skills           []Skill    `json:"skills"`
+}
+
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*10) / 10
+}
+
+// Summarize builds the summary from assessments, newest first, and lessons,
+// all in one language. Without an assessment the learner is not placed and
+// their level is 0.
+func Summarize(assessments []Assessment, lessons []Lesson, now time.Time) Summary {
+	var s Summary
+	if len(assessments) > 0 {
+		s.Level = assessments[0].Level
+		at := assessments[0].At
+		s.AssessedAt = &at
+	}
+	s.Band = placement.Band(s.Level)
+
+	var studied, recent time.Duration
+	for _, l := range lessons {
+		if l.End.After(now) {
+			continue
+		}
+		s.LessonsCompleted++
+		studied += l.End.Sub(l.Start)
+		if now.Sub(l.End) <= PACE_WINDOW {
+			recent += l.End.Sub(l.Start)
+		}
+	}
+	s.HoursCompleted = hours(studied)
+	s.WeeklyHours = math.Round(recent.Hours()*7*24/PACE_WINDOW.Hours()*10) / 10
+
+	for _, skill := range placement.SKILLS {
+		entry := Skill{Skill: skill}
+		seen := 0
+		for _, a := range assessments {
+			level, ok := a.Skills[skill]
+			if !ok {
+				continue
+			}
+			if seen == 0 {
+				entry.Level = level
+			} else {
+				entry.Change = entry.Level - level
+				break
+			}
+			seen++
+		}
+		entry.Band = placement.Band(entry.Level)
+		s.Skills = append(s.Skills, entry)
+	}
+	return s
+}
+
+// Goal is what a learner aims for. StartLevel is their level when they set
+// it, so the percentage measures this goal only.
+type Goal struct {
+	StartLevel  int
+	TargetLevel int
+	TargetDate  time.Time // zero for no deadline
+	Since       time.Time // when the goal was set
+}
+
+type GoalProgress struct {
+	Achieved       bool           `json:"achieved"`
+	Percent        int            `json:"percent"`
+	HoursRemaining float64        `json:"hours_remaining"`
+	ProjectedDate  string         `json:"projected_date"` // yyyy-MM-dd, "" without a pace to project from
+	OnTrack        bool           `json:"on_track"`       // projected by the target date, or no date set
+	NeededWeekly   float64        `json:"needed_weekly"`  // weekly hours that would make the target date, 0 without one
+	SkillGaps      map[string]int `json:"skill_gaps"`     // levels each skill is below the target
+}
+
+// Project works out how far along the learner is towards goal.
+func Project(goal Goal, s Summary, lessons []Lesson, now time.Time) GoalProgress {
+	target := min(max(goal.TargetLevel, 1), len(GUIDED_HOURS)-1)
+	level := min(max(s.Level, 0), len(GUIDED_HOURS)-1)
+	p := GoalProgress{SkillGaps: map[string]int{}}
+	for _, skill := range s.Skills {
+		if gap := target - skill.Level; gap > 0 {
+			p.SkillGaps[skill.Skill] = gap
+		}
+	}
+	if level >= target {
+		p.Achieved, p.Percent, p.OnTrack = true, 100, true
+		return p
+	}
+
+	// study since the level was last measured (or the goal set) counts on
+	// top of it
+	from := goal.Since
+	if s.AssessedAt != nil && s.AssessedAt.After(from) {
+		from = *s.AssessedAt
+	}
+	var studied time.Duration
+	for _, l := range lessons {
+		if l.End.After(from) && !l.End.After(now) {
+			studied += l.End.Sub(l.Start)
+		}
+	}
+	reached := min(GUIDED_HOURS[level]+studied.Hours(), GUIDED_HOURS[target])
+	start := GUIDED_HOURS[min(max(goal.StartLevel, 0), target)]
+	if need := GUIDED_HOURS[target] - start; need > 0 {
+		p.Percent = int(max(reached-start, 0) * 100 / need)
+	}
+	p.HoursRemaining = hours(time.Duration((GUIDED_HOURS[target] - reached) * float64(time.Hour)))
+
+	if s.WeeklyHours > 0 {
+		weeks := p.HoursRemaining / s.WeeklyHours
+		p.ProjectedDate = now.Add(time.Duration(weeks * 7 * 24 * float64(time.Hour))).Format(DATE_FORMAT)
+	}
+	if goal.TargetDate.IsZero() {
+		p.OnTrack = true
+		return p
+	}
+	if weeks := goal.TargetDate.Sub(now).Hours() / (7 * 24); weeks > 0 {
+		p.NeededWeekly = math.Ceil(p.HoursRemaining/weeks*10) / 10
+	}
+	p.OnTrack = len(p.ProjectedDate) > 0 && p.ProjectedDate <= goal.TargetDate.Format(DATE_FORMAT)
+	return p
+}
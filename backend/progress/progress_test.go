@@ -0,0 +1,96 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langbridge/backend/placement"
+)
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// weekly returns n one-hour lessons a week for the given weeks, ending
+// before now, plus one booked for tomorrow.
+func weekly(n, weeks int) []Lesson {
+	var lessons []Lesson
+	for w := 0; w < weeks; w++ {
+		for i := 0; i < n; i++ {
+			start := now.Add(-time.Duration(w*7+i+1) * 24 * time.Hour)
+			lessons = append(lessons, Lesson{Start: start, End: start.Add(time.Hour)})
+		}
+	}
+	tomorrow := now.Add(24 * time.Hour)
+	return append(lessons, Lesson{Start: tomorrow, End: tomorrow.Add(time.Hour)})
+}
+
+func TestSummarize(t *testing.T) {
+	assessments := []Assessment{
+		{Level: 4, At: now.Add(-24 * time.Hour), Skills: map[string]int{placement.SKILL_GRAMMAR: 4, placement.SKILL_READING: 5}},
+		{Level: 3, At: now.Add(-90 * 24 * time.Hour), Skills: map[string]int{placement.SKILL_GRAMMAR: 3}},
+	}
+	s := Summarize(assessments, weekly(2, 10), now)
+	if s.Level != 4 || s.Band != "B2" || s.AssessedAt == nil {
+		t.Fatalf("level %d %s", s.Level, s.Band)
+	}
+	if s.LessonsCompleted != 20 || s.HoursCompleted != 20 || s.WeeklyHours != 2 {
+		t.Errorf("lessons %d, hours %v, weekly %v", s.LessonsCompleted, s.HoursCompleted, s.WeeklyHours)
+	}
+	for _, skill := range s.Skills {
+		switch skill.Skill {
+		case placement.SKILL_GRAMMAR:
+			if skill.Level != 4 || skill.Change != 1 {
+				t.Errorf("grammar %+v", skill)
+			}
+		case placement.SKILL_READING:
+			if skill.Level != 5 || skill.Change != 0 {
+				t.Errorf("reading %+v", skill)
+			}
+		case placement.SKILL_LISTENING:
+			if skill.Level != 0 || skill.Band != "" {
+				t.Errorf("listening %+v", skill)
+			}
+		}
+	}
+
+	if s := Summarize(nil, weekly(1, 1), now); s.Level != 0 || s.Band != "" || s.AssessedAt != nil {
+		t.Errorf("without assessments: %+v", s)
+	}
+}
+
+func TestProject(t *testing.T) {
+	// B1 -> B2 is 200 hours; 8 done since the goal, one a week on average
+	lessons := weekly(4, 2)
+	s := Summarize(nil, lessons, now)
+	s.Level = 3
+	goal := Goal{StartLevel: 3, TargetLevel: 4, Since: now.Add(-30 * 24 * time.Hour), TargetDate: now.AddDate(1, 0, 0)}
+	p := Project(goal, s, lessons, now)
+	if p.Achieved || p.Percent != 4 || p.HoursRemaining != 192 {
+		t.Fatalf("%+v", p)
+	}
+	// 192 weeks at that pace, well past the date
+	if p.OnTrack || p.ProjectedDate != now.AddDate(0, 0, 192*7).Format(DATE_FORMAT) {
+		t.Errorf("projected %s, on track %v", p.ProjectedDate, p.OnTrack)
+	}
+	if p.NeededWeekly < 3.6 || p.NeededWeekly > 3.8 {
+		t.Errorf("needed weekly %v", p.NeededWeekly)
+	}
+
+	goal.TargetDate = time.Time{}
+	if p := Project(goal, s, lessons, now); !p.OnTrack || p.NeededWeekly != 0 {
+		t.Errorf("no deadline: %+v", p)
+	}
+
+	s.Level = 4
+	if p := Project(goal, s, lessons, now); !p.Achieved || p.Percent != 100 {
+		t.Errorf("achieved: %+v", p)
+	}
+}
+
+func TestExamLevel(t *testing.T) {
+	if exam, level, ok := ExamLevel(" fce "); !ok || exam != "FCE" || level != 4 {
+		t.Fatal(exam, level, ok)
+	}
+	if _, _, ok := ExamLevel("TOEFL"); ok {
+		t.Fatal("TOEFL accepted")
+	}
+}
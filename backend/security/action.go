@@ -0,0 +1,95 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action tokens are short lived, single purpose tokens that travel in emailed
+// links (email verification, password reset, ...). They are sealed with the
+// same AES-GCM key as login tokens, so they cannot be forged or altered.
+// Link tokens are the long lived kind, sealed with the data key instead.
+
+const (
+	ACTION_VERIFY_EMAIL   = "verify_email"
+	ACTION_RESET_PASSWORD = "reset_password"
+	ACTION_LOGIN_2FA      = "login_2fa"     // second login step, not emailed
+	ACTION_MEMBER_INVITE  = "member_invite" // subject is the UserMember id
+	ACTION_UNSUBSCRIBE    = "unsubscribe"   // suffixed with ":<event>:<channel>"
+)
+
+var (
+	ErrActionTokenInvalid = errors.New("action token invalid")
+	ErrActionTokenExpired = errors.New("action token expired")
+)
+
+func IssueActionToken(purpose string, userID uint64, ttl time.Duration) (string, error) {
+	plain, err := actionPlain(purpose, userID, ttl)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(plain)
+}
+
+// ParseActionToken returns the user id carried by token if it was issued for
+// purpose and has not expired.
+func ParseActionToken(purpose, token string) (uint64, error) {
+	plain, err := Decrypt(token)
+	if err != nil {
+		return 0, ErrActionTokenInvalid
+	}
+	return parseActionPlain(purpose, plain)
+}
+
+// IssueLinkToken is an action token for links that stay valid longer than a
+// token key does, such as unsubscribe links. It is sealed with the data key
+// ring, whose keys never retire.
+func IssueLinkToken(purpose string, userID uint64, ttl time.Duration) (string, error) {
+	plain, err := actionPlain(purpose, userID, ttl)
+	if err != nil {
+		return "", err
+	}
+	return SealData(plain)
+}
+
+// ParseLinkToken parses a token from IssueLinkToken, or one issued with
+// IssueActionToken before the link moved to the data key.
+func ParseLinkToken(purpose, token string) (uint64, error) {
+	plain, err := OpenData(token)
+	if err != nil {
+		return ParseActionToken(purpose, token)
+	}
+	return parseActionPlain(purpose, plain)
+}
+
+func actionPlain(purpose string, userID uint64, ttl time.Duration) ([]byte, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s|%d|%d|%s", purpose, userID, time.Now().Add(ttl).Unix(), hex.EncodeToString(nonce))), nil
+}
+
+func parseActionPlain(purpose, plain string) (uint64, error) {
+	parts := strings.Split(plain, "|")
+	if len(parts) != 4 || parts[0] != purpose {
+		return 0, ErrActionTokenInvalid
+	}
+	userID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, ErrActionTokenInvalid
+	}
+	expireTs, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrActionTokenInvalid
+	}
+	if time.Now().Unix() > expireTs {
+		return 0, ErrActionTokenExpired
+	}
+	return userID, nil
+}
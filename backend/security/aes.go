@@ -7,11 +7,97 @@ import (
 	"encoding/base64"
 	"errors"
 	"io"
+	"strings"
+	"sync"
 )
 
-var key = []byte("0123456789abcdef")
+var (
+	defaultRing     *KeyRing
+	defaultRingErr  error
+	defaultRingOnce sync.Once
 
+	defaultDataRing = sync.OnceValues(LoadDataKeyRing)
+)
+
+// DefaultKeyRing returns the process wide key ring. It is built on first use
+// so that variables from .env are visible by then.
+func DefaultKeyRing() (*KeyRing, error) {
+	defaultRingOnce.Do(func() {
+		defaultRing, defaultRingErr = LoadKeyRing()
+	})
+	return defaultRing, defaultRingErr
+}
+
+// Encrypt seals plaintext with the active key of the default key ring.
 func Encrypt(plaintext []byte) (string, error) {
+	ring, err := DefaultKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return ring.Encrypt(plaintext)
+}
+
+// Decrypt opens a token sealed by Encrypt with any key still accepted by the
+// default key ring.
+func Decrypt(encrypted string) (string, error) {
+	ring, err := DefaultKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return ring.Decrypt(encrypted)
+}
+
+// DefaultDataKeyRing returns the process wide ring for data at rest.
+func DefaultDataKeyRing() (*KeyRing, error) {
+	return defaultDataRing()
+}
+
+// SealData seals plaintext that is stored, or handed out for longer than a
+// token key lives, with the active data key.
+func SealData(plaintext []byte) (string, error) {
+	ring, err := DefaultDataKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return ring.Encrypt(plaintext)
+}
+
+// OpenData opens what SealData sealed, with any data key ever configured.
+func OpenData(sealed string) (string, error) {
+	ring, err := DefaultDataKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return ring.Decrypt(sealed)
+}
+
+// Encrypt seals plaintext with the active key, producing "<key id>.<base64>".
+func (r *KeyRing) Encrypt(plaintext []byte) (string, error) {
+	key, err := r.activeKey()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(key.secret, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return key.id + keyIDSeparator + ciphertext, nil
+}
+
+func (r *KeyRing) Decrypt(encrypted string) (string, error) {
+	kid, ciphertext, found := strings.Cut(encrypted, keyIDSeparator)
+	if !found {
+		// tokens issued before key ids were introduced
+		kid, ciphertext = r.legacy, encrypted
+	}
+	key, err := r.acceptedKey(kid)
+	if err != nil {
+		return "", err
+	}
+	return open(key.secret, ciphertext)
+}
+
+func seal(key, plaintext []byte) (string, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
@@ -31,7 +117,7 @@ func Encrypt(plaintext []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-func Decrypt(encrypted string) (string, error) {
+func open(key []byte, encrypted string) (string, error) {
 	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
 		return "", err
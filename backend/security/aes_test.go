@@ -4,6 +4,7 @@ import (
 	"log"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAES(t *testing.T) {
@@ -17,3 +18,40 @@ func TestAES(t *testing.T) {
 	result := strings.Split(o, "|")
 	log.Println(result)
 }
+
+func TestActionToken(t *testing.T) {
+	token, err := IssueActionToken(ACTION_VERIFY_EMAIL, 42, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := ParseActionToken(ACTION_VERIFY_EMAIL, token)
+	if err != nil || userID != 42 {
+		t.Fatalf("expected user 42, got %d %v", userID, err)
+	}
+	if _, err := ParseActionToken("other_purpose", token); err != ErrActionTokenInvalid {
+		t.Fatalf("expected purpose mismatch, got %v", err)
+	}
+
+	expired, _ := IssueActionToken(ACTION_VERIFY_EMAIL, 42, -time.Minute)
+	if _, err := ParseActionToken(ACTION_VERIFY_EMAIL, expired); err != ErrActionTokenExpired {
+		t.Fatalf("expected expired, got %v", err)
+	}
+}
+
+func TestLinkToken(t *testing.T) {
+	token, err := IssueLinkToken(ACTION_UNSUBSCRIBE, 42, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := ParseLinkToken(ACTION_UNSUBSCRIBE, token); err != nil || userID != 42 {
+		t.Fatalf("expected user 42, got %d %v", userID, err)
+	}
+	if _, err := ParseActionToken(ACTION_UNSUBSCRIBE, token); err != ErrActionTokenInvalid {
+		t.Fatalf("link token opened with the token keys: %v", err)
+	}
+	// links sent before they moved to the data key
+	old, _ := IssueActionToken(ACTION_UNSUBSCRIBE, 42, time.Hour)
+	if userID, err := ParseLinkToken(ACTION_UNSUBSCRIBE, old); err != nil || userID != 42 {
+		t.Fatalf("expected user 42, got %d %v", userID, err)
+	}
+}
@@ -0,0 +1,90 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMalformed = errors.New("token malformed")
+	ErrTokenExpired   = errors.New("token expired")
+)
+
+// Claims is the payload of every login token, whatever interceptor reads it.
+type Claims struct {
+	UserID      uint64   `json:"uid"`
+	UserNo      string   `json:"uno,omitempty"`
+	Wallet      string   `json:"wal,omitempty"`
+	SessionID   string   `json:"sid,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	IssuedAt    int64    `json:"iat"`
+	ExpiresAt   int64    `json:"exp"`
+}
+
+func NewClaims(userID uint64, userNo string, ttl time.Duration) Claims {
+	now := time.Now()
+	return Claims{
+		UserID:    userID,
+		UserNo:    userNo,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+func (c Claims) Expired(now time.Time) bool {
+	return now.Unix() > c.ExpiresAt
+}
+
+// Encode seals the claims into an XAUTH token.
+func (c Claims) Encode() (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(raw)
+}
+
+// ParseClaims opens an XAUTH token and checks its expiry. Tokens in the
+// pre-claims "user_id,user_no,issued_at" format are still understood and
+// given legacyTokenDuration from their issue time.
+func ParseClaims(token string, legacyTokenDuration time.Duration) (*Claims, error) {
+	plain, err := Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if strings.HasPrefix(plain, "{") {
+		if err := json.Unmarshal([]byte(plain), &claims); err != nil {
+			return nil, ErrTokenMalformed
+		}
+	} else {
+		parts := strings.Split(plain, ",")
+		if len(parts) != 3 {
+			return nil, ErrTokenMalformed
+		}
+		userID, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, ErrTokenMalformed
+		}
+		issuedAt, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, ErrTokenMalformed
+		}
+		claims = Claims{
+			UserID:    userID,
+			UserNo:    parts[1],
+			IssuedAt:  issuedAt,
+			ExpiresAt: issuedAt + int64(legacyTokenDuration.Seconds()),
+		}
+	}
+
+	if claims.Expired(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
@@ -0,0 +1,44 @@
+package security
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClaimsRoundTrip(t *testing.T) {
+	token, err := NewClaims(7, "2506123454", time.Hour).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseClaims(token, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 7 || claims.UserNo != "2506123454" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	expired, _ := NewClaims(7, "2506123454", -time.Minute).Encode()
+	if _, err := ParseClaims(expired, time.Hour); err != ErrTokenExpired {
+		t.Fatalf("expected expired, got %v", err)
+	}
+}
+
+func TestClaimsLegacyFormat(t *testing.T) {
+	legacy, _ := Encrypt([]byte(fmt.Sprintf("%d,%s,%d", 9, "2506000001", time.Now().Unix())))
+	claims, err := ParseClaims(legacy, time.Hour)
+	if err != nil || claims.UserID != 9 || claims.UserNo != "2506000001" {
+		t.Fatalf("legacy token not parsed: %+v %v", claims, err)
+	}
+
+	stale, _ := Encrypt([]byte(fmt.Sprintf("%d,%s,%d", 9, "2506000001", time.Now().Add(-2*time.Hour).Unix())))
+	if _, err := ParseClaims(stale, time.Hour); err != ErrTokenExpired {
+		t.Fatalf("expected stale legacy token to expire, got %v", err)
+	}
+
+	bad, _ := Encrypt([]byte("1|wallet|solana"))
+	if _, err := ParseClaims(bad, time.Hour); err != ErrTokenMalformed {
+		t.Fatalf("expected malformed, got %v", err)
+	}
+}
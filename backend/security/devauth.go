@@ -0,0 +1,62 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/log"
+)
+
+// DEV_TOKEN_PREFIX marks an XAUTH value naming a configured dev identity
+// instead of carrying a sealed token, e.g. "dev:student".
+const DEV_TOKEN_PREFIX = "dev:"
+
+const devTokenDuration = time.Hour
+
+var ErrDevAuthForbidden = errors.New("dev identity provider must not be enabled in this build or profile")
+
+// CheckDevAuth fails when the dev identity provider is switched on where it
+// must not be: in binaries built with the prod tag, or outside the dev and
+// local profiles. main refuses to start on error.
+func CheckDevAuth() error {
+	conf := config.GetConfig()
+	if !conf.DevAuth.Enabled {
+		return nil
+	}
+	if prodBuild || !devProfile(conf.Profile) {
+		return ErrDevAuthForbidden
+	}
+	log.Warnf("[DevAuth] !!! dev identity provider ENABLED for profile %q with %d test users — never run this in production !!!",
+		conf.Profile, len(conf.DevAuth.Users))
+	return nil
+}
+
+func DevAuthEnabled() bool {
+	conf := config.GetConfig()
+	return conf.DevAuth.Enabled && !prodBuild && devProfile(conf.Profile)
+}
+
+// DevIdentity resolves "dev:<name>" into claims for the configured test user.
+func DevIdentity(value string) (*Claims, bool) {
+	name, found := strings.CutPrefix(value, DEV_TOKEN_PREFIX)
+	if !found || !DevAuthEnabled() {
+		return nil, false
+	}
+	for _, u := range config.GetConfig().DevAuth.Users {
+		if u.Name == name {
+			claims := NewClaims(u.UserID, u.UserNo, devTokenDuration)
+			claims.Roles = append([]string(nil), u.Roles...)
+			claims.Permissions = PermissionsFor(u.Roles, nil)
+			log.Warnf("[DevAuth] impersonating dev user %q as user_id=%d roles=%v", u.Name, u.UserID, u.Roles)
+			return &claims, true
+		}
+	}
+	log.Warnf("[DevAuth] unknown dev user %q", name)
+	return nil, false
+}
+
+func devProfile(profile string) bool {
+	return profile == "dev" || profile == "local"
+}
@@ -0,0 +1,5 @@
+//go:build prod
+
+package security
+
+const prodBuild = true
@@ -0,0 +1,29 @@
+package security
+
+import "testing"
+
+func TestDevIdentity(t *testing.T) {
+	// config/dev.yml enables the provider with a "student" test user
+	if prodBuild {
+		if err := CheckDevAuth(); err != ErrDevAuthForbidden {
+			t.Fatalf("prod build must refuse dev auth, got %v", err)
+		}
+		if _, ok := DevIdentity("dev:student"); ok {
+			t.Fatal("prod build resolved a dev identity")
+		}
+		return
+	}
+	if err := CheckDevAuth(); err != nil {
+		t.Fatal(err)
+	}
+	claims, ok := DevIdentity("dev:student")
+	if !ok || claims.UserID != 1 || len(claims.Roles) != 1 || claims.Roles[0] != "student" {
+		t.Fatalf("unexpected dev identity %+v %v", claims, ok)
+	}
+	if _, ok := DevIdentity("dev:nobody"); ok {
+		t.Fatal("unknown dev user resolved")
+	}
+	if _, ok := DevIdentity("123456"); ok {
+		t.Fatal("value without dev prefix resolved")
+	}
+}
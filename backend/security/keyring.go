@@ -0,0 +1,193 @@
+package security
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/config"
+)
+
+const keyIDSeparator = "."
+
+var (
+	ErrNoActiveKey     = errors.New("no active token key configured")
+	ErrNoActiveDataKey = errors.New("no active data key configured")
+	ErrUnknownKey      = errors.New("token key unknown or retired")
+)
+
+type ringKey struct {
+	id       string
+	secret   []byte
+	notAfter time.Time
+}
+
+func (k ringKey) retired(now time.Time) bool {
+	return !k.notAfter.IsZero() && now.After(k.notAfter)
+}
+
+// KeyRing holds every AES key tokens may be sealed with. New tokens always use
+// the active key; the others are kept so that tokens issued before a rotation
+// stay valid until their key's NotAfter passes.
+type KeyRing struct {
+	keys   map[string]ringKey
+	active string
+	legacy string
+}
+
+// LoadKeyRing builds the ring from LB_TOKEN_KEYS / LB_TOKEN_ACTIVE_KEY when
+// set, otherwise from the security section of the config.
+//
+// LB_TOKEN_KEYS is a comma separated list of id:base64secret[:notAfter].
+//
+// Tokens issued without a key id were sealed with a key anyone can read from
+// the source, so the legacy key that opens them is only loaded in the dev and
+// local profiles.
+func LoadKeyRing() (*KeyRing, error) {
+	conf := config.GetConfig().Security
+
+	if env := os.Getenv("LB_TOKEN_KEYS"); len(env) > 0 {
+		keys, err := parseEnvKeys("LB_TOKEN_KEYS", env)
+		if err != nil {
+			return nil, err
+		}
+		conf.TokenKeys = keys
+	}
+	if env := os.Getenv("LB_TOKEN_ACTIVE_KEY"); len(env) > 0 {
+		conf.ActiveKey = env
+	}
+	if devProfile(config.GetConfig().Profile) {
+		return NewKeyRing(conf)
+	}
+	for _, k := range conf.TokenKeys {
+		if k.Legacy {
+			return nil, fmt.Errorf("token key %s: legacy keys are only accepted in the dev and local profiles", k.ID)
+		}
+	}
+	return NewKeyRing(conf)
+}
+
+// LoadDataKeyRing builds the ring for data at rest from LB_DATA_KEYS /
+// LB_DATA_ACTIVE_KEY when set, otherwise from the config. LB_DATA_KEYS has
+// the LB_TOKEN_KEYS format without notAfter.
+func LoadDataKeyRing() (*KeyRing, error) {
+	conf := config.GetConfig().Security
+
+	if env := os.Getenv("LB_DATA_KEYS"); len(env) > 0 {
+		keys, err := parseEnvKeys("LB_DATA_KEYS", env)
+		if err != nil {
+			return nil, err
+		}
+		conf.DataKeys = keys
+	}
+	if env := os.Getenv("LB_DATA_ACTIVE_KEY"); len(env) > 0 {
+		conf.ActiveDataKey = env
+	}
+
+	return NewDataKeyRing(conf)
+}
+
+func parseEnvKeys(name, env string) ([]config.TokenKeyConfig, error) {
+	var keys []config.TokenKeyConfig
+	for _, entry := range strings.Split(env, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%s entry %q must be id:secret[:notAfter]", name, entry)
+		}
+		k := config.TokenKeyConfig{ID: parts[0], Secret: parts[1]}
+		if len(parts) == 3 {
+			k.NotAfter = parts[2]
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// NewDataKeyRing builds the ring for data that must stay readable for as
+// long as it is stored, such as TOTP secrets and unsubscribe links. Its keys
+// never retire: rotating means adding a key and making it active.
+func NewDataKeyRing(conf config.SecurityConfig) (*KeyRing, error) {
+	for _, k := range conf.DataKeys {
+		if len(k.NotAfter) > 0 || k.Legacy {
+			return nil, fmt.Errorf("data key %s: data keys cannot expire or be legacy", k.ID)
+		}
+	}
+	ring, err := NewKeyRing(config.SecurityConfig{ActiveKey: conf.ActiveDataKey, TokenKeys: conf.DataKeys})
+	if errors.Is(err, ErrNoActiveKey) {
+		return nil, ErrNoActiveDataKey
+	}
+	return ring, err
+}
+
+func NewKeyRing(conf config.SecurityConfig) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]ringKey), active: conf.ActiveKey}
+
+	for _, k := range conf.TokenKeys {
+		if len(k.ID) == 0 || strings.Contains(k.ID, keyIDSeparator) {
+			return nil, fmt.Errorf("token key id %q is empty or contains %q", k.ID, keyIDSeparator)
+		}
+		if _, exists := ring.keys[k.ID]; exists {
+			return nil, fmt.Errorf("token key id %q repeated", k.ID)
+		}
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("token key %s: secret is not base64: %v", k.ID, err)
+		}
+		if n := len(secret); n != 16 && n != 24 && n != 32 {
+			return nil, fmt.Errorf("token key %s: secret must be 16, 24 or 32 bytes, got %d", k.ID, n)
+		}
+		key := ringKey{id: k.ID, secret: secret}
+		if k.Legacy && len(k.NotAfter) == 0 {
+			return nil, fmt.Errorf("token key %s: a legacy key must have a notAfter", k.ID)
+		}
+		if len(k.NotAfter) > 0 {
+			if key.notAfter, err = parseNotAfter(k.NotAfter); err != nil {
+				return nil, fmt.Errorf("token key %s: %v", k.ID, err)
+			}
+		}
+		ring.keys[k.ID] = key
+		if k.Legacy {
+			ring.legacy = k.ID
+		}
+	}
+
+	if _, ok := ring.keys[ring.active]; !ok {
+		return nil, ErrNoActiveKey
+	}
+	return ring, nil
+}
+
+// ActiveKeyID is the id new tokens are issued with.
+func (r *KeyRing) ActiveKeyID() string {
+	return r.active
+}
+
+func (r *KeyRing) activeKey() (ringKey, error) {
+	key, ok := r.keys[r.active]
+	if !ok || key.retired(time.Now()) {
+		return ringKey{}, ErrNoActiveKey
+	}
+	return key, nil
+}
+
+func (r *KeyRing) acceptedKey(id string) (ringKey, error) {
+	key, ok := r.keys[id]
+	if !ok || key.retired(time.Now()) {
+		return ringKey{}, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func parseNotAfter(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("notAfter %q must be 2006-01-02 or RFC3339", v)
+	}
+	return t.Add(24 * time.Hour), nil
+}
@@ -0,0 +1,110 @@
+package security
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langbridge/backend/config"
+)
+
+const (
+	testKeyA = "YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE="
+	testKeyB = "YmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmJiYmI="
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	before, err := NewKeyRing(config.SecurityConfig{
+		ActiveKey: "a",
+		TokenKeys: []config.TokenKeyConfig{{ID: "a", Secret: testKeyA}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _ := before.Encrypt([]byte("1,2,3"))
+	if !strings.HasPrefix(oldToken, "a.") {
+		t.Fatalf("token should carry key id: %s", oldToken)
+	}
+
+	// rotate: b becomes active, a is still accepted until tomorrow
+	after, err := NewKeyRing(config.SecurityConfig{
+		ActiveKey: "b",
+		TokenKeys: []config.TokenKeyConfig{
+			{ID: "a", Secret: testKeyA, NotAfter: time.Now().Add(time.Hour).Format(time.RFC3339)},
+			{ID: "b", Secret: testKeyB},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := after.Decrypt(oldToken); err != nil || plain != "1,2,3" {
+		t.Fatalf("old token rejected during rotation window: %v", err)
+	}
+	newToken, _ := after.Encrypt([]byte("4,5,6"))
+	if !strings.HasPrefix(newToken, "b.") {
+		t.Fatalf("new token should use active key: %s", newToken)
+	}
+
+	// a has been retired
+	retired, _ := NewKeyRing(config.SecurityConfig{
+		ActiveKey: "b",
+		TokenKeys: []config.TokenKeyConfig{
+			{ID: "a", Secret: testKeyA, NotAfter: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+			{ID: "b", Secret: testKeyB},
+		},
+	})
+	if _, err := retired.Decrypt(oldToken); err != ErrUnknownKey {
+		t.Fatalf("expected retired key to be refused, got %v", err)
+	}
+}
+
+func TestKeyRingConfigErrors(t *testing.T) {
+	if _, err := NewKeyRing(config.SecurityConfig{ActiveKey: "missing"}); err != ErrNoActiveKey {
+		t.Fatalf("expected missing active key error, got %v", err)
+	}
+	if _, err := NewKeyRing(config.SecurityConfig{
+		ActiveKey: "a",
+		TokenKeys: []config.TokenKeyConfig{{ID: "a", Secret: "c2hvcnQ="}},
+	}); err == nil {
+		t.Fatal("expected short secret to be refused")
+	}
+}
+
+func TestDataKeyRing(t *testing.T) {
+	if _, err := NewDataKeyRing(config.SecurityConfig{
+		ActiveDataKey: "a",
+		DataKeys:      []config.TokenKeyConfig{{ID: "a", Secret: testKeyA, NotAfter: "2030-01-01"}},
+	}); err == nil {
+		t.Fatal("expiring data key accepted")
+	}
+	if _, err := NewDataKeyRing(config.SecurityConfig{ActiveKey: "a",
+		TokenKeys: []config.TokenKeyConfig{{ID: "a", Secret: testKeyA}}}); err != ErrNoActiveDataKey {
+		t.Fatalf("token keys used as data keys: %v", err)
+	}
+
+	before, _ := NewDataKeyRing(config.SecurityConfig{
+		ActiveDataKey: "a",
+		DataKeys:      []config.TokenKeyConfig{{ID: "a", Secret: testKeyA}},
+	})
+	sealed, _ := before.Encrypt([]byte("JBSWY3DPEHPK3PXP"))
+	after, err := NewDataKeyRing(config.SecurityConfig{
+		ActiveDataKey: "b",
+		DataKeys:      []config.TokenKeyConfig{{ID: "a", Secret: testKeyA}, {ID: "b", Secret: testKeyB}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := after.Decrypt(sealed); err != nil || plain != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("data sealed before a rotation unreadable: %v", err)
+	}
+}
+
+func TestLegacyKeyNeedsNotAfter(t *testing.T) {
+	_, err := NewKeyRing(config.SecurityConfig{
+		ActiveKey: "a",
+		TokenKeys: []config.TokenKeyConfig{{ID: "a", Secret: testKeyA}, {ID: "v0", Secret: testKeyB, Legacy: true}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "notAfter") {
+		t.Fatalf("legacy key without notAfter accepted: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package security
+
+import "time"
+
+// LockoutDuration is how long to lock after the given number of consecutive
+// failures: nothing below threshold, then base doubling with every further
+// failure, never more than max.
+func LockoutDuration(failures, threshold int, base, max time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	d := base
+	for i := threshold; i < failures; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return min(d, max)
+}
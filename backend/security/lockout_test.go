@@ -0,0 +1,25 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{20, time.Hour},
+	}
+	for _, tc := range cases {
+		if got := LockoutDuration(tc.failures, 5, time.Minute, time.Hour); got != tc.want {
+			t.Errorf("failures=%d: got %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
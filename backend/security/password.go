@@ -0,0 +1,121 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Stored password hashes use the PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// Accounts created before the argon2id switch hold a bare hex sha256 of the
+// password; those still verify and are reported as needing a rehash.
+
+type passwordParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var defaultPasswordParams = passwordParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var ErrInvalidPasswordHash = errors.New("invalid password hash format")
+
+func HashPassword(password string) (string, error) {
+	p := defaultPasswordParams
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches the stored hash, and
+// whether the stored hash should be replaced with a fresh HashPassword result
+// (legacy sha256 hashes, or argon2id hashes built with outdated parameters).
+func VerifyPassword(password, stored string) (match bool, rehash bool) {
+	if isLegacyPasswordHash(stored) {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(stored))) == 1, true
+	}
+
+	p, salt, key, err := decodePasswordHash(stored)
+	if err != nil {
+		return false, false
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false
+	}
+
+	d := defaultPasswordParams
+	outdated := p.Memory != d.Memory || p.Iterations != d.Iterations || p.Parallelism != d.Parallelism ||
+		p.SaltLength != d.SaltLength || p.KeyLength != d.KeyLength
+	return true, outdated
+}
+
+func isLegacyPasswordHash(stored string) bool {
+	if len(stored) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(stored)
+	return err == nil
+}
+
+func decodePasswordHash(stored string) (passwordParams, []byte, []byte, error) {
+	var p passwordParams
+
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
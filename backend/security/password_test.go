@@ -0,0 +1,46 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") {
+		t.Fatalf("unexpected hash format %s", hash)
+	}
+
+	if match, rehash := VerifyPassword("correct horse", hash); !match || rehash {
+		t.Fatalf("expected match without rehash, got match=%v rehash=%v", match, rehash)
+	}
+	if match, _ := VerifyPassword("wrong horse", hash); match {
+		t.Fatal("wrong password matched")
+	}
+
+	other, _ := HashPassword("correct horse")
+	if other == hash {
+		t.Fatal("expected salted hashes to differ")
+	}
+}
+
+func TestPasswordLegacyHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("12345678"))
+	legacy := hex.EncodeToString(sum[:])
+
+	match, rehash := VerifyPassword("12345678", legacy)
+	if !match || !rehash {
+		t.Fatalf("expected legacy match with rehash, got match=%v rehash=%v", match, rehash)
+	}
+	if match, _ := VerifyPassword("87654321", legacy); match {
+		t.Fatal("wrong password matched legacy hash")
+	}
+	if match, _ := VerifyPassword("12345678", "not-a-hash"); match {
+		t.Fatal("garbage hash matched")
+	}
+}
@@ -0,0 +1,70 @@
+package security
+
+import "slices"
+
+const (
+	ROLE_STUDENT = "student"
+	ROLE_PARENT  = "parent"
+	ROLE_TEACHER = "teacher"
+	ROLE_ADMIN   = "admin"
+	ROLE_MEMBER  = "member" // a family member's own login, managed by the guardian
+)
+
+const (
+	PERM_ALL = "*"
+
+	PERM_COURSE_BOOK      = "course.book"
+	PERM_FAMILY_MANAGE    = "family.manage"
+	PERM_TEACHER_PROFILE  = "teacher.profile"
+	PERM_TEACHER_SCHEDULE = "teacher.schedule"
+	PERM_ADMIN_USERS      = "admin.users"
+	PERM_ADMIN_LOCKOUTS   = "admin.lockouts"
+	PERM_ADMIN_AUDIT      = "admin.audit"
+	PERM_ADMIN_CHANNELS   = "admin.channels"
+	PERM_ADMIN_PLACEMENT  = "admin.placement"
+)
+
+// rolePermissions are granted by holding a role; per-user grants come on top.
+var rolePermissions = map[string][]string{
+	ROLE_STUDENT: {PERM_COURSE_BOOK, PERM_FAMILY_MANAGE},
+	ROLE_PARENT:  {PERM_COURSE_BOOK, PERM_FAMILY_MANAGE},
+	ROLE_MEMBER:  {}, // booking is granted per member by the guardian
+	ROLE_TEACHER: {PERM_TEACHER_PROFILE, PERM_TEACHER_SCHEDULE},
+	ROLE_ADMIN:   {PERM_ALL},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// PermissionsFor merges the permissions of roles with extra per-user grants.
+func PermissionsFor(roles []string, extra []string) []string {
+	var perms []string
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	for _, p := range extra {
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+func (c Claims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Claims) HasPermission(perm string) bool {
+	return slices.Contains(c.Permissions, PERM_ALL) || slices.Contains(c.Permissions, perm)
+}
@@ -0,0 +1,33 @@
+package security
+
+import "testing"
+
+func TestPermissionsFor(t *testing.T) {
+	claims := Claims{
+		UserID:      5,
+		Roles:       []string{ROLE_TEACHER},
+		Permissions: PermissionsFor([]string{ROLE_TEACHER, ROLE_TEACHER}, []string{PERM_COURSE_BOOK}),
+	}
+	if len(claims.Permissions) != 3 {
+		t.Fatalf("expected deduplicated permissions, got %v", claims.Permissions)
+	}
+	if !claims.HasRole(ROLE_STUDENT, ROLE_TEACHER) || claims.HasRole(ROLE_ADMIN) {
+		t.Fatalf("unexpected roles check for %v", claims.Roles)
+	}
+	if !claims.HasPermission(PERM_TEACHER_SCHEDULE) || !claims.HasPermission(PERM_COURSE_BOOK) {
+		t.Fatal("missing granted permission")
+	}
+	if claims.HasPermission(PERM_ADMIN_USERS) {
+		t.Fatal("teacher must not administer users")
+	}
+
+	admin := Claims{Permissions: PermissionsFor([]string{ROLE_ADMIN}, nil)}
+	if !admin.HasPermission(PERM_ADMIN_USERS) {
+		t.Fatal("admin should hold every permission")
+	}
+
+	member := Claims{Permissions: PermissionsFor([]string{ROLE_MEMBER}, nil)}
+	if member.HasPermission(PERM_COURSE_BOOK) || member.HasPermission(PERM_FAMILY_MANAGE) {
+		t.Fatal("a member login books only when the guardian grants it")
+	}
+}
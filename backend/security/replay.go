@@ -0,0 +1,29 @@
+package security
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRequestTimeInvalid = errors.New("request timestamp invalid")
+	ErrRequestExpired     = errors.New("request timestamp outside allowed window")
+)
+
+// CheckRequestTime accepts a signed request whose TS header (unix seconds)
+// is within maxSkew of now, in either direction.
+func CheckRequestTime(ts string, now time.Time, maxSkew time.Duration) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrRequestTimeInvalid
+	}
+	diff := now.Sub(time.Unix(sec, 0))
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > maxSkew {
+		return ErrRequestExpired
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package security
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckRequestTime(t *testing.T) {
+	now := time.Now()
+	ts := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	if err := CheckRequestTime(ts(-time.Minute), now, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckRequestTime(ts(time.Minute), now, 5*time.Minute); err != nil {
+		t.Fatal("small forward clock skew should pass", err)
+	}
+	if err := CheckRequestTime(ts(-10*time.Minute), now, 5*time.Minute); err != ErrRequestExpired {
+		t.Fatalf("expected stale request refused, got %v", err)
+	}
+	if err := CheckRequestTime(ts(10*time.Minute), now, 5*time.Minute); err != ErrRequestExpired {
+		t.Fatalf("expected future request refused, got %v", err)
+	}
+	if err := CheckRequestTime("abc", now, 5*time.Minute); err != ErrRequestTimeInvalid {
+		t.Fatalf("expected invalid timestamp, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	SIG_SHA256      = "SHA256"
+	SIG_HMAC_SHA256 = "HMAC-SHA256"
+	SIG_ED25519     = "ED25519"
+)
+
+// SignedRequest is everything a channel signature may cover.
+type SignedRequest struct {
+	AppID     string
+	RequestID string
+	Ts        string
+	Ver       string
+	Method    string // HTTP method
+	URI       string // path and query
+	BodyHash  string // hex sha256 of the request body
+}
+
+// Legacy is the payload of the original SHA256 method: the key is appended
+// and the whole string hashed.
+func (r SignedRequest) Legacy() string {
+	return fmt.Sprintf("%s%s%s%s", r.AppID, r.RequestID, r.Ts, r.Ver)
+}
+
+// Canonical is the payload of every other method. Covering method, URI and
+// body means a signature is only good for the request it was made for.
+func (r SignedRequest) Canonical(sigMethod string) []byte {
+	return []byte(strings.Join([]string{
+		sigMethod,
+		strings.ToUpper(r.Method),
+		r.URI,
+		r.AppID,
+		r.RequestID,
+		r.Ts,
+		r.Ver,
+		r.BodyHash,
+	}, "\n"))
+}
+
+// NewChannelSecret returns a random AppKey for the SHA256 and HMAC methods.
+func NewChannelSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidEd25519PublicKey reports whether key is a base64 Ed25519 public key.
+func ValidEd25519PublicKey(key string) bool {
+	_, ok := parseEd25519PublicKey(key)
+	return ok
+}
+
+func HashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChannelKeys are the credentials of one channel; each method picks the one
+// it needs.
+type ChannelKeys struct {
+	Secret    string
+	PublicKey string
+}
+
+type SignatureVerifier interface {
+	Verify(req SignedRequest, keys ChannelKeys, sig string) bool
+}
+
+type VerifierFunc func(req SignedRequest, keys ChannelKeys, sig string) bool
+
+func (f VerifierFunc) Verify(req SignedRequest, keys ChannelKeys, sig string) bool {
+	return f(req, keys, sig)
+}
+
+var (
+	verifiers   = map[string]SignatureVerifier{}
+	verifiersMu sync.RWMutex
+)
+
+func RegisterVerifier(method string, v SignatureVerifier) {
+	verifiersMu.Lock()
+	verifiers[method] = v
+	verifiersMu.Unlock()
+}
+
+func LookupVerifier(method string) (SignatureVerifier, bool) {
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+	v, ok := verifiers[method]
+	return v, ok
+}
+
+func init() {
+	RegisterVerifier(SIG_SHA256, VerifierFunc(verifySHA256))
+	RegisterVerifier(SIG_HMAC_SHA256, VerifierFunc(verifyHMAC))
+	RegisterVerifier(SIG_ED25519, VerifierFunc(verifyEd25519))
+}
+
+func verifySHA256(req SignedRequest, keys ChannelKeys, sig string) bool {
+	if len(keys.Secret) == 0 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(req.Legacy() + keys.Secret))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(sig))) == 1
+}
+
+func verifyHMAC(req SignedRequest, keys ChannelKeys, sig string) bool {
+	if len(keys.Secret) == 0 {
+		return false
+	}
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(keys.Secret))
+	mac.Write(req.Canonical(SIG_HMAC_SHA256))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// verifyEd25519 expects the channel public key and the signature in base64.
+func verifyEd25519(req SignedRequest, keys ChannelKeys, sig string) bool {
+	pub, ok := parseEd25519PublicKey(keys.PublicKey)
+	if !ok {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, req.Canonical(SIG_ED25519), signature)
+}
+
+func parseEd25519PublicKey(key string) (ed25519.PublicKey, bool) {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, false
+	}
+	return ed25519.PublicKey(pub), true
+}
@@ -0,0 +1,86 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+var signedReq = SignedRequest{
+	AppID:     "web",
+	RequestID: "1718000000-abc",
+	Ts:        "1718000000",
+	Ver:       "1.0",
+	Method:    "POST",
+	URI:       "/spwapi/auth/course/confirm",
+	BodyHash:  HashBody([]byte(`{"course_id":1}`)),
+}
+
+func TestVerifySHA256Legacy(t *testing.T) {
+	sum := sha256.Sum256([]byte(signedReq.Legacy() + "app-key"))
+	v, _ := LookupVerifier(SIG_SHA256)
+	if !v.Verify(signedReq, ChannelKeys{Secret: "app-key"}, hex.EncodeToString(sum[:])) {
+		t.Fatal("legacy signature refused")
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("app-key"))
+	mac.Write(signedReq.Canonical(SIG_HMAC_SHA256))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	v, _ := LookupVerifier(SIG_HMAC_SHA256)
+	if !v.Verify(signedReq, ChannelKeys{Secret: "app-key"}, sig) {
+		t.Fatal("hmac signature refused")
+	}
+
+	other := signedReq
+	other.URI = "/spwapi/auth/profile/update"
+	if v.Verify(other, ChannelKeys{Secret: "app-key"}, sig) {
+		t.Fatal("signature replayed against another endpoint")
+	}
+	other = signedReq
+	other.BodyHash = HashBody([]byte(`{"course_id":2}`))
+	if v.Verify(other, ChannelKeys{Secret: "app-key"}, sig) {
+		t.Fatal("signature accepted for another body")
+	}
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, signedReq.Canonical(SIG_ED25519)))
+	keys := ChannelKeys{PublicKey: base64.StdEncoding.EncodeToString(pub)}
+
+	v, _ := LookupVerifier(SIG_ED25519)
+	if !v.Verify(signedReq, keys, sig) {
+		t.Fatal("ed25519 signature refused")
+	}
+	other := signedReq
+	other.Method = "GET"
+	if v.Verify(other, keys, sig) {
+		t.Fatal("signature accepted for another method")
+	}
+}
+
+func TestChannelCredentials(t *testing.T) {
+	a, err := NewChannelSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewChannelSecret()
+	if len(a) != 64 || a == b {
+		t.Fatalf("weak channel secrets %q %q", a, b)
+	}
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if !ValidEd25519PublicKey(base64.StdEncoding.EncodeToString(pub)) {
+		t.Fatal("valid public key refused")
+	}
+	if ValidEd25519PublicKey(base64.StdEncoding.EncodeToString(pub[:16])) || ValidEd25519PublicKey("not base64!") {
+		t.Fatal("invalid public key accepted")
+	}
+}
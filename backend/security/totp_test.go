@@ -0,0 +1,46 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA1 key "12345678901234567890"
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for ts, want := range vectors {
+		if got := hotp(key, uint64(ts/totpPeriod), 8); got != want {
+			t.Errorf("T=%d: got %s, want %s", ts, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1718000000, 0)
+	code, _ := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+	step, ok := VerifyTOTP(secret, code, now)
+	if !ok || step != now.Unix()/totpPeriod-1 {
+		t.Fatalf("drifted code refused: %v %d", ok, step)
+	}
+	stale, _ := TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+	if _, ok := VerifyTOTP(secret, stale, now); ok {
+		t.Fatal("stale code accepted")
+	}
+
+	backup, _ := GenerateBackupCodes(2)
+	if len(backup[0]) != 9 || BackupCodeDigest(backup[0]) != BackupCodeDigest(" "+backup[0]) {
+		t.Fatalf("unexpected backup code %q", backup[0])
+	}
+}
@@ -0,0 +1,37 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/system"
+)
+
+func revokeKey(userID uint64) string {
+	return fmt.Sprintf("lb:token:revoke:%d", userID)
+}
+
+// revokeUserTokens invalidates every login token issued to the user up to now.
+func revokeUserTokens(userID uint64) error {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return fmt.Errorf("redis unavailable")
+	}
+	return rdb.Set(context.Background(), revokeKey(userID), time.Now().Unix(), common.TOKEN_DURATION).Err()
+}
+
+// TokensRevokedAt returns the unix time before which the user's tokens are no
+// longer accepted, or 0 when nothing has been revoked.
+func TokensRevokedAt(userID uint64) int64 {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return 0
+	}
+	ts, err := rdb.Get(context.Background(), revokeKey(userID)).Int64()
+	if err != nil {
+		return 0
+	}
+	return ts
+}
@@ -0,0 +1,293 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langbridge/backend/api/common"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/system"
+	"github.com/redis/go-redis/v9"
+)
+
+// A Session is one signed-in device. Access tokens carry its id and are only
+// accepted while it exists; refresh tokens are single use and rotate on every
+// refresh.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     uint64 `json:"user_id"`
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreateTime int64  `json:"create_time"`
+	LastActive int64  `json:"last_active"`
+}
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrRefreshInvalid  = errors.New("refresh token invalid")
+	errNoRedis         = errors.New("redis unavailable")
+)
+
+var ctx = context.Background()
+
+func sessionKey(sid string) string {
+	return "lb:session:" + sid
+}
+
+func refreshKey(sid string) string {
+	return "lb:session:refresh:" + sid
+}
+
+// spentKey holds the digest of the refresh token the session last rotated
+// away from, so a replay of it can be told apart from a forged token.
+func spentKey(sid string) string {
+	return "lb:session:refresh:spent:" + sid
+}
+
+// rotateScript swaps the stored refresh digest only when the presented one
+// matches. It returns 1 when rotated, 2 when the presented token is the one
+// already spent, and 0 otherwise.
+var rotateScript = redis.NewScript(`
+local stored = redis.call("GET", KEYS[1])
+if stored == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "EX", ARGV[3])
+	redis.call("SET", KEYS[2], ARGV[1], "EX", ARGV[3])
+	return 1
+end
+if redis.call("GET", KEYS[2]) == ARGV[1] then
+	return 2
+end
+return 0
+`)
+
+func userSessionsKey(userID uint64) string {
+	return fmt.Sprintf("lb:user:sessions:%d", userID)
+}
+
+// Create starts a session and returns it with its first refresh token.
+func Create(userID uint64, device, ip, userAgent string) (*Session, string, error) {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return nil, "", errNoRedis
+	}
+
+	sid, err := randomString(12)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().Unix()
+	s := &Session{
+		ID:         sid,
+		UserID:     userID,
+		Device:     device,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreateTime: now,
+		LastActive: now,
+	}
+	refresh, err := newRefreshToken(sid)
+	if err != nil {
+		return nil, "", err
+	}
+	raw, _ := json.Marshal(s)
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(sid), raw, common.REFRESH_TOKEN_DURATION)
+		pipe.Set(ctx, refreshKey(sid), digest(refresh), common.REFRESH_TOKEN_DURATION)
+		pipe.SAdd(ctx, userSessionsKey(userID), sid)
+		pipe.Expire(ctx, userSessionsKey(userID), common.REFRESH_TOKEN_DURATION)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return s, refresh, nil
+}
+
+func Get(sid string) (*Session, error) {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return nil, errNoRedis
+	}
+	raw, err := rdb.Get(ctx, sessionKey(sid)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Active reports whether sid is a live session of userID.
+func Active(userID uint64, sid string) bool {
+	s, err := Get(sid)
+	if err != nil {
+		if !errors.Is(err, ErrSessionNotFound) {
+			log.Error("[Session] lookup error: ", sid, err)
+		}
+		return false
+	}
+	return s.UserID == userID
+}
+
+// Rotate consumes a refresh token and returns the session with a new one.
+// Presenting an already used refresh token revokes the session, since it
+// means the token has been copied; any other wrong token is only refused.
+func Rotate(refresh string) (*Session, string, error) {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return nil, "", errNoRedis
+	}
+
+	sid, _, found := strings.Cut(refresh, ".")
+	if !found || len(sid) == 0 {
+		return nil, "", ErrRefreshInvalid
+	}
+	s, err := Get(sid)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, "", ErrRefreshInvalid
+		}
+		return nil, "", err
+	}
+
+	next, err := newRefreshToken(sid)
+	if err != nil {
+		return nil, "", err
+	}
+	ttl := int64(common.REFRESH_TOKEN_DURATION / time.Second)
+	rotated, err := rotateScript.Run(ctx, rdb, []string{refreshKey(sid), spentKey(sid)},
+		digest(refresh), digest(next), ttl).Int()
+	if err != nil {
+		return nil, "", err
+	}
+	if rotated == 2 {
+		log.Error("[Session] refresh token reuse, revoking session: ", sid)
+		Revoke(s.UserID, sid)
+		return nil, "", ErrRefreshInvalid
+	}
+	if rotated != 1 {
+		return nil, "", ErrRefreshInvalid
+	}
+
+	s.LastActive = time.Now().Unix()
+	raw, _ := json.Marshal(s)
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKey(sid), raw, common.REFRESH_TOKEN_DURATION)
+		pipe.Expire(ctx, userSessionsKey(s.UserID), common.REFRESH_TOKEN_DURATION)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return s, next, nil
+}
+
+// List returns the user's live sessions, most recently active first.
+func List(userID uint64) ([]Session, error) {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return nil, errNoRedis
+	}
+	sids, err := rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Session, 0, len(sids))
+	for _, sid := range sids {
+		s, err := Get(sid)
+		if err != nil {
+			if errors.Is(err, ErrSessionNotFound) {
+				rdb.SRem(ctx, userSessionsKey(userID), sid)
+			}
+			continue
+		}
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastActive > result[j].LastActive
+	})
+	return result, nil
+}
+
+// Revoke ends one session of the user.
+func Revoke(userID uint64, sid string) error {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return errNoRedis
+	}
+	s, err := Get(sid)
+	if err != nil {
+		return err
+	}
+	if s.UserID != userID {
+		return ErrSessionNotFound
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(sid), refreshKey(sid), spentKey(sid))
+		pipe.SRem(ctx, userSessionsKey(userID), sid)
+		return nil
+	})
+	return err
+}
+
+// RevokeAll ends every session of the user, and also refuses any session-less
+// token issued before now.
+func RevokeAll(userID uint64) error {
+	rdb := system.GetRedis()
+	if rdb == nil {
+		return errNoRedis
+	}
+	sids, err := rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sid := range sids {
+			pipe.Del(ctx, sessionKey(sid), refreshKey(sid), spentKey(sid))
+		}
+		pipe.Del(ctx, userSessionsKey(userID))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return revokeUserTokens(userID)
+}
+
+func newRefreshToken(sid string) (string, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return sid + "." + secret, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func digest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,57 @@
+// Package storage puts uploaded files somewhere they can be served from:
+// a local directory or an S3-compatible bucket. Callers only deal in keys
+// such as "avatars/10001/ab12-512.jpg" and the public URL of a key.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/langbridge/backend/config"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// New builds the backend selected by conf.Backend.
+func New(conf config.StorageConfig) (Storage, error) {
+	switch conf.Backend {
+	case "", "local":
+		return NewLocal(conf.Local)
+	case "s3":
+		return NewS3(conf.S3, nil)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", conf.Backend)
+}
+
+var defaultStorage = sync.OnceValues(func() (Storage, error) {
+	return New(config.GetConfig().Storage)
+})
+
+// Default is the storage configured for this instance.
+func Default() (Storage, error) {
+	return defaultStorage()
+}
+
+// checkKey refuses keys that could escape the storage root or would be
+// rewritten by a URL parser: absolute paths, "..", backslashes.
+func checkKey(key string) error {
+	if len(key) == 0 || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
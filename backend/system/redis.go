@@ -3,6 +3,7 @@ package system
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -170,3 +171,15 @@ func RedisExpire(key string, expireTime time.Duration) {
 		return
 	}
 }
+
+var errNoRedis = errors.New("redis not started")
+
+// Throttle reports whether the caller may proceed: the first call for key in
+// each window returns true, later calls return false until the window ends.
+// Without Redis it returns false and an error; callers should refuse.
+func Throttle(key string, window time.Duration) (bool, error) {
+	if rdb == nil {
+		return false, errNoRedis
+	}
+	return rdb.SetNX(ctx, key, time.Now().Unix(), window).Result()
+}
@@ -0,0 +1,40 @@
+// Package timezone resolves the zones dates are stored and shown in: the
+// fixed zone of stored lesson times and each user's own zone.
+package timezone
+
+import (
+	"time"
+
+	"github.com/langbridge/backend/config"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/utils"
+	"gorm.io/gorm"
+)
+
+// Lesson is the zone lesson_date, start_time and end_time are stored in.
+// Without one configured it is the server's zone, which bookings made before
+// the setting existed were written in.
+func Lesson() *time.Location {
+	if loc, ok := utils.LoadZone(config.GetConfig().LessonTimezone); ok {
+		return loc
+	}
+	return time.Local
+}
+
+// ForUser is the user's profile timezone, else the timezone of their
+// living country, else the lesson zone.
+func ForUser(db *gorm.DB, userID uint64) *time.Location {
+	var profile model.UserProfile
+	db.Model(&model.UserProfile{}).Where("user_id = ?", userID).First(&profile)
+	if loc, ok := utils.LoadZone(profile.Timezone); ok {
+		return loc
+	}
+	if profile.LivingCountryID > 0 {
+		var country model.DictCountry
+		db.Model(&model.DictCountry{}).Where("id = ?", profile.LivingCountryID).First(&country)
+		if loc, ok := utils.LoadZone(country.Timezone); ok {
+			return loc
+		}
+	}
+	return Lesson()
+}
@@ -0,0 +1,202 @@
+// Package twofactor manages TOTP enrollment and checks second-factor codes,
+// either a TOTP code or one of the user's backup codes.
+package twofactor
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/security"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+const (
+	ISSUER            = "LangBridge"
+	BACKUP_CODE_COUNT = 10
+)
+
+var (
+	ErrNotEnrolled    = errors.New("two-factor authentication not enrolled")
+	ErrAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrCodeInvalid    = errors.New("two-factor code invalid")
+)
+
+func get(userID uint64) (*model.UserTwoFactor, error) {
+	var tf model.UserTwoFactor
+	err := system.GetDb().Model(&model.UserTwoFactor{}).Where("user_id = ?", userID).First(&tf).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// Enabled reports whether the user must pass a second factor to sign in.
+func Enabled(userID uint64) (bool, error) {
+	tf, err := get(userID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+// Begin starts, or restarts, an enrollment and returns the new secret. It is
+// not enforced until confirmed with Enable.
+func Begin(userID uint64) (string, error) {
+	tf, err := get(userID)
+	if err != nil && !errors.Is(err, ErrNotEnrolled) {
+		return "", err
+	}
+	if tf != nil && tf.Enabled {
+		return "", ErrAlreadyEnabled
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := security.SealData([]byte(secret))
+	if err != nil {
+		return "", err
+	}
+	if tf == nil {
+		tf = &model.UserTwoFactor{UserID: userID, AddTime: time.Now()}
+	}
+	tf.Secret = sealed
+	tf.LastStep = 0
+	tf.UpdateTime = time.Now()
+	if err := system.GetDb().Save(tf).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Enable confirms an enrollment with a first code and returns the backup
+// codes, which are only ever shown this once.
+func Enable(userID uint64, code string) ([]string, error) {
+	tf, err := get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if err := checkTOTP(tf, code); err != nil {
+		return nil, err
+	}
+
+	backup, err := security.GenerateBackupCodes(BACKUP_CODE_COUNT)
+	if err != nil {
+		return nil, err
+	}
+	digests := make([]string, len(backup))
+	for i, c := range backup {
+		digests[i] = security.BackupCodeDigest(c)
+	}
+	raw, _ := json.Marshal(digests)
+	err = system.GetDb().Model(&model.UserTwoFactor{}).Where("id = ?", tf.ID).
+		Updates(map[string]interface{}{"enabled": true, "backup_codes": string(raw), "update_time": time.Now()}).Error
+	if err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Verify accepts a current TOTP code or an unused backup code, which is then
+// spent.
+func Verify(userID uint64, code string) error {
+	tf, err := get(userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled {
+		return ErrNotEnrolled
+	}
+	err = checkTOTP(tf, code)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrCodeInvalid) {
+		// an unreadable secret must not lock the user out of their backup codes
+		log.Error("[TwoFactor] check totp error: ", userID, err)
+	}
+	return spendBackupCode(tf, code)
+}
+
+func Disable(userID uint64) error {
+	return system.GetDb().Where("user_id = ?", userID).Delete(&model.UserTwoFactor{}).Error
+}
+
+// openSecret reads the TOTP secret. Secrets sealed with the login token
+// keys, before they had a data key of their own, are moved over on the way.
+func openSecret(tf *model.UserTwoFactor) (string, error) {
+	secret, err := security.OpenData(tf.Secret)
+	if err == nil {
+		return secret, nil
+	}
+	secret, legacyErr := security.Decrypt(tf.Secret)
+	if legacyErr != nil {
+		return "", err
+	}
+	if sealed, err := security.SealData([]byte(secret)); err == nil {
+		system.GetDb().Model(&model.UserTwoFactor{}).Where("id = ? and secret = ?", tf.ID, tf.Secret).
+			Update("secret", sealed)
+	}
+	return secret, nil
+}
+
+func checkTOTP(tf *model.UserTwoFactor, code string) error {
+	secret, err := openSecret(tf)
+	if err != nil {
+		return err
+	}
+	step, ok := security.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrCodeInvalid
+	}
+	// only move forward, so each code works once even under concurrent use
+	result := system.GetDb().Model(&model.UserTwoFactor{}).
+		Where("id = ? and last_step < ?", tf.ID, step).
+		Update("last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCodeInvalid
+	}
+	return nil
+}
+
+func spendBackupCode(tf *model.UserTwoFactor, code string) error {
+	var digests []string
+	if len(tf.BackupCodes) > 0 {
+		if err := json.Unmarshal([]byte(tf.BackupCodes), &digests); err != nil {
+			return err
+		}
+	}
+	i := slices.Index(digests, security.BackupCodeDigest(code))
+	if i < 0 {
+		return ErrCodeInvalid
+	}
+	remaining, _ := json.Marshal(slices.Delete(digests, i, i+1))
+	result := system.GetDb().Model(&model.UserTwoFactor{}).
+		Where("id = ? and backup_codes = ?", tf.ID, tf.BackupCodes).
+		Updates(map[string]interface{}{"backup_codes": string(remaining), "update_time": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCodeInvalid
+	}
+	return nil
+}
@@ -128,3 +128,98 @@ func CompareDate(date1, date2 string) (int, error) {
 		return 0, nil
 	}
 }
+
+// Age returns how many full years old someone born on birthday (yyyy-MM-dd)
+// is at now. ok is false when birthday is not a past date.
+func Age(birthday string, now time.Time) (age int, ok bool) {
+	born, err := time.Parse("2006-01-02", birthday)
+	if err != nil || born.After(now) {
+		return 0, false
+	}
+	age = now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age, true
+}
+
+// LessonSpan is when a lesson stored as a date plus "15:04[:05]" wall
+// clocks in loc starts and ends. An end clock not after the start clock
+// means the lesson runs past midnight.
+func LessonSpan(date time.Time, startClock, endClock string, loc *time.Location) (start, end time.Time, err error) {
+	start, err = lessonInstant(date, startClock, loc)
+	if err != nil {
+		return
+	}
+	end, err = lessonInstant(date, endClock, loc)
+	if err != nil {
+		return
+	}
+	if !end.After(start) {
+		end, err = lessonInstant(date.AddDate(0, 0, 1), endClock, loc)
+	}
+	return
+}
+
+func lessonInstant(date time.Time, clock string, loc *time.Location) (time.Time, error) {
+	var t time.Time
+	var err error
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err = time.Parse(layout, clock); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := date.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+}
+
+// WallClock splits t into the calendar date (midnight in loc) and the
+// "15:04:05" clock it shows in loc.
+func WallClock(t time.Time, loc *time.Location) (time.Time, string) {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), t.Format("15:04:05")
+}
+
+// LoadZone loads an IANA zone such as "Europe/London". The empty name and
+// "Local" are refused: they would mean the server's zone, not the user's.
+func LoadZone(name string) (*time.Location, bool) {
+	if len(name) == 0 || name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	return loc, err == nil
+}
+
+// QuietUntil reports whether t falls inside the daily quiet window from
+// start to end ("22:00" to "07:30") as read on the clock in loc, and if so
+// when the window ends. A window whose end is before its start runs over
+// midnight; equal or unparsable bounds mean no quiet hours.
+func QuietUntil(t time.Time, loc *time.Location, start, end string) (time.Time, bool) {
+	from, err1 := time.Parse("15:04", start)
+	to, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil || from.Equal(to) {
+		return time.Time{}, false
+	}
+	local := t.In(loc)
+	y, m, d := local.Date()
+	at := func(day int, clock time.Time) time.Time {
+		return time.Date(y, m, day, clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+	// the window that began today, and the one that began yesterday and
+	// may still be running past midnight
+	for _, day := range []int{d, d - 1} {
+		windowStart := at(day, from)
+		windowEnd := at(day, to)
+		if !windowEnd.After(windowStart) {
+			windowEnd = at(day+1, to)
+		}
+		if !local.Before(windowStart) && local.Before(windowEnd) {
+			return windowEnd, true
+		}
+	}
+	return time.Time{}, false
+}
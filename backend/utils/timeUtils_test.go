@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAge(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[string]int{
+		"2010-06-15": 15,
+		"2010-06-16": 14,
+		"2010-01-31": 15,
+		"2024-12-31": 0,
+	}
+	for birthday, want := range cases {
+		if got, ok := Age(birthday, now); !ok || got != want {
+			t.Errorf("Age(%s) = %d, %v; want %d", birthday, got, ok, want)
+		}
+	}
+	for _, bad := range []string{"", "15/06/2010", "2030-01-01"} {
+		if _, ok := Age(bad, now); ok {
+			t.Errorf("Age(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLessonSpanAcrossZones(t *testing.T) {
+	london, ok := LoadZone("Europe/London")
+	shanghai, ok2 := LoadZone("Asia/Shanghai")
+	if !ok || !ok2 {
+		t.Skip("zoneinfo not available")
+	}
+	if _, ok := LoadZone("Local"); ok {
+		t.Fatal("Local accepted")
+	}
+
+	// 18:30-19:30 in London on a summer day is 01:30-02:30 the next day in Shanghai
+	start, end, err := LessonSpan(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), "18:30", "19:30", london)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, clock := WallClock(start, shanghai)
+	if date.Format("2006-01-02") != "2026-07-02" || clock != "01:30:00" || end.Sub(start) != time.Hour {
+		t.Fatalf("got %s %s, %s", date.Format("2006-01-02"), clock, end.Sub(start))
+	}
+
+	// a stored lesson running past midnight
+	start, end, err = LessonSpan(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), "23:30:00", "00:30:00", time.UTC)
+	if err != nil || end.Sub(start) != time.Hour {
+		t.Fatalf("midnight lesson: %s %v", end.Sub(start), err)
+	}
+	if _, _, err := LessonSpan(time.Now(), "25:00", "26:00", time.UTC); err == nil {
+		t.Fatal("invalid clock accepted")
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	at := func(day, hour, min int) time.Time { return time.Date(2026, 3, day, hour, min, 0, 0, loc) }
+	cases := []struct {
+		now        time.Time
+		start, end string
+		quiet      bool
+		until      time.Time
+	}{
+		{at(10, 23, 0), "22:00", "07:30", true, at(11, 7, 30)},
+		{at(11, 6, 59), "22:00", "07:30", true, at(11, 7, 30)},
+		{at(11, 7, 30), "22:00", "07:30", false, time.Time{}},
+		{at(11, 13, 0), "12:00", "14:00", true, at(11, 14, 0)},
+		{at(11, 15, 0), "12:00", "14:00", false, time.Time{}},
+		{at(11, 23, 0), "", "", false, time.Time{}},
+		{at(11, 23, 0), "08:00", "08:00", false, time.Time{}},
+	}
+	for _, c := range cases {
+		// the same instant seen from UTC must give the same answer
+		until, quiet := QuietUntil(c.now.UTC(), loc, c.start, c.end)
+		if quiet != c.quiet || !until.Equal(c.until) {
+			t.Errorf("%s %s-%s: got %v %s", c.now, c.start, c.end, quiet, until)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package validation checks and normalises user-entered profile data:
+// phone numbers to E.164, language tags to BCP 47 with an ISO 639-1 primary
+// language, and dates. Handlers collect problems in Errors so the client gets
+// every bad field at once instead of the first one.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/langbridge/backend/utils"
+)
+
+const (
+	CODE_REQUIRED = "required"
+	CODE_INVALID  = "invalid"
+	CODE_TOO_LONG = "too_long"
+
+	DATE_FORMAT = "2006-01-02"
+)
+
+var (
+	ErrPhone    = errors.New("not a valid phone number")
+	ErrLanguage = errors.New("not a valid language code")
+	ErrDate     = errors.New("not a valid date, expected yyyy-MM-dd")
+)
+
+// FieldError names the request field (by its JSON name) that was refused.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Check records err against field, if there is one. It returns whether err
+// was nil so callers can skip using a value that did not validate.
+func (e *Errors) Check(field string, err error) bool {
+	if err == nil {
+		return true
+	}
+	e.Add(field, CODE_INVALID, err.Error())
+	return false
+}
+
+// Phone normalises raw to E.164 ("+" and up to 15 digits). Numbers written
+// in international form ("+44 20 ...", "0044 20 ...") keep their own
+// country code; national numbers get phoneCode, the dialling code of the
+// user's country, after dropping a leading trunk 0.
+func Phone(raw, phoneCode string) (string, error) {
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrPhone
+		}
+	}
+	number := digits.String()
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(trimmed, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	default:
+		cc := strings.TrimLeft(strings.TrimSpace(phoneCode), "+")
+		if len(cc) == 0 || len(cc) > 3 || strings.Trim(cc, "0123456789") != "" {
+			return "", fmt.Errorf("%w: country dialling code unknown", ErrPhone)
+		}
+		// Italy keeps the 0 of landline numbers after the country code
+		if cc != "39" {
+			number = strings.TrimPrefix(number, "0")
+		}
+		number = cc + number
+	}
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrPhone
+	}
+	return "+" + number, nil
+}
+
+// Language normalises a BCP 47 tag of the form language[-Script][-REGION],
+// where language is ISO 639-1. Case is fixed and "_" accepted as separator:
+// "zh_hant_tw" becomes "zh-Hant-TW".
+func Language(tag string) (string, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(tag), func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 || len(parts) > 3 {
+		return "", ErrLanguage
+	}
+	lang := strings.ToLower(parts[0])
+	if !iso639_1[lang] {
+		return "", fmt.Errorf("%w: %q", ErrLanguage, tag)
+	}
+	out := []string{lang}
+	rest := parts[1:]
+	if len(rest) > 0 && len(rest[0]) == 4 && isAlpha(rest[0]) {
+		out = append(out, strings.ToUpper(rest[0][:1])+strings.ToLower(rest[0][1:]))
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		region := rest[0]
+		switch {
+		case len(region) == 2 && isAlpha(region):
+			out = append(out, strings.ToUpper(region))
+		case len(region) == 3 && strings.Trim(region, "0123456789") == "":
+			out = append(out, region)
+		default:
+			return "", fmt.Errorf("%w: %q", ErrLanguage, tag)
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		return "", fmt.Errorf("%w: %q", ErrLanguage, tag)
+	}
+	return strings.Join(out, "-"), nil
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Date checks a yyyy-MM-dd date no later than now and not before 1900, the
+// shape birthdays are stored in.
+func Date(s string, now time.Time) (string, error) {
+	d, err := time.Parse(DATE_FORMAT, strings.TrimSpace(s))
+	if err != nil {
+		return "", ErrDate
+	}
+	if d.After(now) || d.Year() < 1900 {
+		return "", fmt.Errorf("%w: out of range", ErrDate)
+	}
+	return d.Format(DATE_FORMAT), nil
+}
+
+// Timezone checks an IANA zone name such as "America/New_York".
+func Timezone(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if _, ok := utils.LoadZone(name); !ok {
+		return "", fmt.Errorf("unknown timezone %q", name)
+	}
+	return name, nil
+}
+
+// Clock checks a 24-hour HH:mm time of day such as "22:00".
+func Clock(s string) (string, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return "", errors.New("not a valid time, expected HH:mm")
+	}
+	return t.Format("15:04"), nil
+}
+
+// Email checks a bare address, without display name.
+func Email(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", errors.New("not a valid email address")
+	}
+	return s, nil
+}
+
+// URL checks an absolute http or https URL.
+func URL(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return "", errors.New("not a valid http(s) URL")
+	}
+	return u.String(), nil
+}
+
+// MaxLen adds a too_long error when s has more than n characters.
+func (e *Errors) MaxLen(field, s string, n int) {
+	if utf8.RuneCountInString(s) > n {
+		e.Add(field, CODE_TOO_LONG, fmt.Sprintf("at most %d characters", n))
+	}
+}
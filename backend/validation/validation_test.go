@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPhone(t *testing.T) {
+	cases := []struct{ raw, code, want string }{
+		{"020 7946 0958", "+44", "+442079460958"},
+		{"(415) 555-0132", "1", "+14155550132"},
+		{"+86 138 0013 8000", "+44", "+8613800138000"},
+		{"0086 13800138000", "", "+8613800138000"},
+		{"06 1234 5678", "39", "+390612345678"},
+	}
+	for _, c := range cases {
+		got, err := Phone(c.raw, c.code)
+		if err != nil || got != c.want {
+			t.Errorf("Phone(%q, %q) = %q, %v; want %q", c.raw, c.code, got, err, c.want)
+		}
+	}
+	for _, raw := range []string{"", "12", "555-CALL-NOW", "+44 20 7946 0958 1234 5678", "44+2079460958", "+0123456789"} {
+		if _, err := Phone(raw, "44"); !errors.Is(err, ErrPhone) {
+			t.Errorf("Phone(%q) accepted", raw)
+		}
+	}
+	if _, err := Phone("2079460958", ""); !errors.Is(err, ErrPhone) {
+		t.Error("national number without a country code accepted")
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	if len(iso639_1) != 183 {
+		t.Fatalf("expected 183 ISO 639-1 codes, got %d", len(iso639_1))
+	}
+	cases := map[string]string{"en": "en", "EN-us": "en-US", "zh_hant_tw": "zh-Hant-TW", "es-419": "es-419", "sr-Latn": "sr-Latn"}
+	for in, want := range cases {
+		if got, err := Language(in); err != nil || got != want {
+			t.Errorf("Language(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "english", "xx", "en-USA", "en-US-x", "eng"} {
+		if _, err := Language(in); !errors.Is(err, ErrLanguage) {
+			t.Errorf("Language(%q) accepted", in)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	if got, err := Date(" 2015-02-28 ", now); err != nil || got != "2015-02-28" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	for _, in := range []string{"2015-02-30", "28/02/2015", "2027-01-01", "1850-01-01"} {
+		if _, err := Date(in, now); !errors.Is(err, ErrDate) {
+			t.Errorf("Date(%q) accepted", in)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	if got, err := Clock(" 7:30 "); err != nil || got != "07:30" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	for _, in := range []string{"24:00", "22:60", "10pm", ""} {
+		if _, err := Clock(in); err == nil {
+			t.Errorf("Clock(%q) accepted", in)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	errs.Check("phone", ErrPhone)
+	errs.Check("email", nil)
+	errs.MaxLen("nick_name", "ünïcödé", 7)
+	errs.MaxLen("rel_desc", "12345678", 7)
+	if len(errs) != 2 || errs.Error() != "phone: "+ErrPhone.Error()+"; rel_desc: "+errs[1].Message {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if errs[0].Field != "phone" || errs[1].Code != CODE_TOO_LONG {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
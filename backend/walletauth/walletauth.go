@@ -0,0 +1,120 @@
+// Package walletauth checks signed wallet challenges issued by the preauth
+// get_msg endpoint and links wallets to user accounts.
+package walletauth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langbridge/backend/codes"
+	"github.com/langbridge/backend/log"
+	"github.com/langbridge/backend/model"
+	"github.com/langbridge/backend/system"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMessageNotFound = errors.New("auth message not found")
+	ErrMessageExpired  = errors.New("auth message expired")
+	ErrBadSignature    = errors.New("invalid sign")
+	ErrWalletLinked    = errors.New("wallet already linked to another account")
+	ErrWalletNotLinked = errors.New("wallet not linked to an account")
+)
+
+// Verify checks sign against auth message id and returns the wallet that
+// signed it. The message is consumed, so a signature works only once.
+func Verify(id uint64, sign string) (string, error) {
+	db := system.GetDb()
+	var authObj model.AuthMessage
+	err := db.Model(&model.AuthMessage{}).Where("id = ?", id).First(&authObj).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrMessageNotFound
+		}
+		return "", err
+	}
+	if authObj.ExpireTime.Before(time.Now()) {
+		return "", ErrMessageExpired
+	}
+	if !authObj.ComputeAuthDigest(sign) {
+		return "", ErrBadSignature
+	}
+
+	result := db.Where("id = ?", authObj.ID).Delete(&model.AuthMessage{})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		// consumed by a concurrent request
+		return "", ErrMessageNotFound
+	}
+	return authObj.AuthKey, nil
+}
+
+// Link attaches wallet to userID, creating the user_wallet row if needed.
+func Link(userID uint64, wallet string) (*model.UserWallet, error) {
+	db := system.GetDb()
+	var userWallet model.UserWallet
+	err := db.Model(&model.UserWallet{}).Where("wallet = ?", wallet).First(&userWallet).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if userWallet.UserID > 0 && userWallet.UserID != userID {
+		return nil, ErrWalletLinked
+	}
+	if userWallet.ID == 0 {
+		userWallet = model.UserWallet{Wallet: wallet, Chain: "solana", CreateTime: time.Now()}
+	}
+	userWallet.UserID = userID
+	if err := db.Save(&userWallet).Error; err != nil {
+		return nil, err
+	}
+	return &userWallet, nil
+}
+
+// Unlink detaches wallet from userID. The user_wallet row stays for the
+// wallet-only history it may carry.
+func Unlink(userID uint64, wallet string) error {
+	result := system.GetDb().Model(&model.UserWallet{}).
+		Where("wallet = ? and user_id = ?", wallet, userID).
+		Update("user_id", 0)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWalletNotLinked
+	}
+	return nil
+}
+
+// Owner returns the account a wallet is linked to.
+func Owner(wallet string) (uint64, error) {
+	var userWallet model.UserWallet
+	err := system.GetDb().Model(&model.UserWallet{}).Where("wallet = ? and user_id > ?", wallet, 0).First(&userWallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrWalletNotLinked
+		}
+		return 0, err
+	}
+	return userWallet.UserID, nil
+}
+
+// ErrorCode maps an error from this package to the response code and message
+// the handlers send.
+func ErrorCode(err error) (int64, string) {
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		return codes.CODE_ERR_OBJ_NOT_FOUND, "record not found"
+	case errors.Is(err, ErrMessageExpired):
+		return codes.CODE_ERR_REQ_EXPIRED, "request expired"
+	case errors.Is(err, ErrBadSignature):
+		return codes.CODE_ERR_SIG_COMMON, "invalid sign"
+	case errors.Is(err, ErrWalletLinked):
+		return codes.CODE_ERR_EXIST_OBJ, "wallet is linked to another account"
+	case errors.Is(err, ErrWalletNotLinked):
+		return codes.CODE_ERR_OBJ_NOT_FOUND, "wallet is not linked to an account"
+	}
+	log.Error("[Wallet] error: ", err)
+	return codes.CODE_ERR_UNKNOWN, "system error"
+}
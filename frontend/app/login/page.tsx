@@ -9,7 +9,6 @@ import * as yup from 'yup';
 import { yupResolver } from '@hookform/resolvers/yup';
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
-import SHA256 from 'crypto-js/sha256';
 import Cookies from 'js-cookie';
 
 const schema = yup.object().shape({
@@ -22,6 +21,8 @@ export default function LoginPage() {
   const [password, setPassword] = useState('');
   const [remember, setRemember] = useState(false);
   const [loading, setLoading] = useState(false);
+  const [challenge, setChallenge] = useState<{ token: string; remember: boolean } | null>(null);
+  const [twoFactorCode, setTwoFactorCode] = useState('');
   const router = useRouter();
   const {
     register,
@@ -36,6 +37,7 @@ export default function LoginPage() {
   const saveLoginData = (token: string, userInfo: any, remember: boolean) => {
     const storage = remember ? localStorage : sessionStorage;
     storage.setItem('token', token);
+    storage.setItem('refreshToken', userInfo.refresh_token || '');
     storage.setItem('userInfo', JSON.stringify(userInfo));
     Cookies.set('token', token, { expires: remember ? 7 : undefined, path: '/' });
     Cookies.set('userInfo', JSON.stringify(userInfo), { expires: remember ? 7 : undefined, path: '/' });
@@ -46,6 +48,8 @@ export default function LoginPage() {
     localStorage.removeItem('token');
     localStorage.removeItem('userInfo');
     sessionStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    sessionStorage.removeItem('refreshToken');
     sessionStorage.removeItem('userInfo');
     Cookies.remove('token', { path: '/' });
     Cookies.remove('userInfo', { path: '/' });
@@ -57,11 +61,13 @@ export default function LoginPage() {
     clearLoginData();
     const payload = {
       login_name: data.loginName,
-      password: SHA256(data.password).toString(),
+      password: data.password,
     };
     try {
       const res = await apiClient.post('/spwapi/login', payload);
-      if (res && res.code === 0) {
+      if (res && res.code === 0 && res.data?.two_factor) {
+        setChallenge({ token: res.data.challenge, remember: data.remember });
+      } else if (res && res.code === 0) {
         toast.success('Login successfully！');
         saveLoginData(res.data.token, res.data, data.remember)
         setTimeout(() => {
@@ -77,11 +83,62 @@ export default function LoginPage() {
     }
   };
 
+  const onVerifyCode = async () => {
+    if (!challenge) return;
+    setLoading(true);
+    try {
+      const res = await apiClient.post('/spwapi/login/2fa', {
+        challenge: challenge.token,
+        code: twoFactorCode.trim(),
+      });
+      if (res && res.code === 0) {
+        toast.success('Login successfully！');
+        saveLoginData(res.data.token, res.data, challenge.remember);
+        setTimeout(() => {
+          router.push('/profile');
+        }, 1500);
+      } else if (res && res.code === 5) {
+        // challenge expired, start over with the password
+        setChallenge(null);
+        toast.error(res.msg);
+      } else {
+        toast.error(res?.msg || 'Verification failed');
+      }
+    } catch (err: any) {
+      toast.error(err?.message || 'Verification failed');
+    } finally {
+      setLoading(false);
+    }
+  };
+
   return (
     <div className="container mx-auto px-6 py-16">
       <ToastContainer position="top-center" autoClose={2000} />
       <div className="max-w-md mx-auto bg-white rounded-xl shadow-md overflow-hidden p-8">
         <h2 className="text-3xl font-bold text-center mb-8 text-gray-800">Login</h2>
+        {challenge ? (
+          <div>
+            <p className="text-gray-700 text-sm mb-4">
+              Enter the 6-digit code from your authenticator app, or one of your backup codes.
+            </p>
+            <input
+              id="twoFactorCode"
+              type="text"
+              autoComplete="one-time-code"
+              value={twoFactorCode}
+              onChange={(e) => setTwoFactorCode(e.target.value)}
+              className="w-full px-4 py-2 border border-gray-300 rounded-md mb-6 focus:outline-none focus:ring-2 focus:ring-blue-500"
+            />
+            <button
+              type="button"
+              disabled={loading || twoFactorCode.trim().length === 0}
+              onClick={onVerifyCode}
+              className="w-full bg-blue-600 text-white py-2 px-4 rounded-md hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-blue-500 focus:ring-offset-2 transition duration-300"
+            >
+              Verify
+            </button>
+          </div>
+        ) : (
         <form onSubmit={handleSubmit(onSubmit)}>
           <div className="mb-6">
             <label htmlFor="email" className="block text-gray-700 text-sm font-medium mb-2">
@@ -135,6 +192,7 @@ export default function LoginPage() {
             Login
           </button>
         </form>
+        )}
         
         <div className="mt-6 text-center">
           <p className="text-gray-600">
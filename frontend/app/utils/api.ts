@@ -44,7 +44,29 @@ class ApiClient {
     };
   }
 
-  private async request<T>(endpoint: string, options: RequestOptions = {}): Promise<T> {
+  // Access tokens are short lived; swap the stored refresh token for a new
+  // pair and report whether the caller should retry.
+  private async refreshToken(): Promise<boolean> {
+    if (typeof window === 'undefined') return false;
+    const storage = localStorage.getItem('refreshToken') ? localStorage : sessionStorage;
+    const refreshToken = storage.getItem('refreshToken');
+    if (!refreshToken) return false;
+
+    const res: any = await this.request('/spwapi/token/refresh', {
+      method: 'POST',
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    }, false);
+    if (!res || res.code !== 0) {
+      storage.removeItem('refreshToken');
+      return false;
+    }
+    storage.setItem('token', res.data.token);
+    storage.setItem('refreshToken', res.data.refresh_token);
+    Cookies.set('token', res.data.token, { path: '/' });
+    return true;
+  }
+
+  private async request<T>(endpoint: string, options: RequestOptions = {}, retry = true): Promise<T> {
     const { params, ...fetchOptions } = options;
     let url = `${this.baseUrl}${endpoint}`;
 
@@ -65,7 +87,12 @@ class ApiClient {
       throw new Error(`HTTP error! status: ${response.status}`);
     }
 
-    return response.json();
+    const body = await response.json();
+    // 15 = CODE_ERR_SECURITY, returned for expired or revoked tokens
+    if (retry && body?.code === 15 && await this.refreshToken()) {
+      return this.request<T>(endpoint, options, false);
+    }
+    return body;
   }
 
   public async get<T>(endpoint: string, params?: Record<string, any>): Promise<T> {